@@ -0,0 +1,204 @@
+// Package admission provides admission policies deciding whether a new key
+// is worth the place of the entry that would be evicted for it.
+package admission
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync/atomic"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// samplesPerSize is the number of accesses recorded per entry of the cache before the frequencies are aged.
+const samplesPerSize = 10
+
+// TinyLFU admits a candidate unless its estimated access frequency is lower than the one of the victim.
+//
+// The frequencies are estimated by a count-min sketch of 4-bit counters,
+// behind a Bloom filter doorkeeper that keeps the keys seen once out of the sketch.
+// Every samplesPerSize*size accesses, the counters are halved and the doorkeeper is cleared,
+// so that the keys that were popular long ago don't stay admitted forever.
+//
+// A TinyLFU is safe for concurrent use. It takes no lock: the counters and the doorkeeper
+// are updated with atomic operations, so that recording the hits of a cache only holding
+// its read lock doesn't serialize them. Concurrent updates may be lost while the counters
+// are halved, which only makes the estimates slightly less accurate.
+type TinyLFU[K comparable] struct {
+	seed       maphash.Seed
+	sketch     sketch
+	doorkeeper doorkeeper
+	samples    atomic.Int64
+	resetAt    int64
+}
+
+var _ types.Admission[int] = (*TinyLFU[int])(nil)
+
+// NewTinyLFU creates a TinyLFU admission policy for a cache holding up to size entries.
+// A larger size makes the estimates more accurate and the aging less frequent, at the cost of memory.
+func NewTinyLFU[K comparable](size int) *TinyLFU[K] {
+	if size <= 0 {
+		panic("admission: size must be greater than 0")
+	}
+
+	return &TinyLFU[K]{
+		seed:       maphash.MakeSeed(),
+		sketch:     newSketch(size),
+		doorkeeper: newDoorkeeper(samplesPerSize * size),
+		resetAt:    int64(samplesPerSize * size),
+	}
+}
+
+// Record records an access to the key.
+func (t *TinyLFU[K]) Record(key K) {
+	h := maphash.Comparable(t.seed, key)
+
+	// the first access only goes to the doorkeeper
+	if t.doorkeeper.add(h) {
+		t.sketch.increment(h)
+	}
+
+	// a single access reaches the reset count, so the counters are aged once
+	if t.samples.Add(1) == t.resetAt {
+		t.sketch.halve()
+		t.doorkeeper.clear()
+		t.samples.Add(-t.resetAt / 2)
+	}
+}
+
+// Estimate returns the estimated number of recent accesses to the key.
+func (t *TinyLFU[K]) Estimate(key K) int {
+	return t.estimate(maphash.Comparable(t.seed, key))
+}
+
+// Admit reports whether the candidate is accessed at least as often as the victim.
+func (t *TinyLFU[K]) Admit(candidate, victim K) bool {
+	return t.estimate(maphash.Comparable(t.seed, candidate)) >= t.estimate(maphash.Comparable(t.seed, victim))
+}
+
+func (t *TinyLFU[K]) estimate(h uint64) int {
+	n := t.sketch.estimate(h)
+	if t.doorkeeper.contains(h) {
+		n++
+	}
+	return n
+}
+
+// sketchDepth is the number of rows of the count-min sketch.
+const sketchDepth = 4
+
+// maxCount is the maximum value of a 4-bit counter.
+const maxCount = 15
+
+// sketch is a count-min sketch of 4-bit counters, packed 16 per word.
+// The words are updated with compare-and-swap loops.
+type sketch struct {
+	rows [sketchDepth][]atomic.Uint64
+	mask uint64
+}
+
+func newSketch(size int) sketch {
+	// four counters per entry and row, rounded up to a power of two, to keep the collisions rare
+	width := nextPowerOfTwo(max(size*4, 16))
+	s := sketch{mask: uint64(width - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]atomic.Uint64, width/16)
+	}
+	return s
+}
+
+// counter returns the word and the bit offset of the counter of the hash in the given row.
+func (s *sketch) counter(h uint64, row int) (word int, shift uint) {
+	// the row indexes are derived from the two halves of the hash
+	i := (h + uint64(row)*(h>>32|1)) & s.mask
+	return int(i / 16), uint(i%16) * 4
+}
+
+func (s *sketch) increment(h uint64) {
+	for row := range s.rows {
+		word, shift := s.counter(h, row)
+		w := &s.rows[row][word]
+		for {
+			old := w.Load()
+			if (old>>shift)&maxCount == maxCount || w.CompareAndSwap(old, old+1<<shift) {
+				break
+			}
+		}
+	}
+}
+
+func (s *sketch) estimate(h uint64) int {
+	n := maxCount
+	for row := range s.rows {
+		word, shift := s.counter(h, row)
+		n = min(n, int((s.rows[row][word].Load()>>shift)&maxCount))
+	}
+	return n
+}
+
+// halve divides all the counters by two.
+func (s *sketch) halve() {
+	for _, row := range s.rows {
+		for i := range row {
+			for {
+				// shift every counter right, dropping the bit carried over from the next counter
+				old := row[i].Load()
+				if row[i].CompareAndSwap(old, (old>>1)&0x7777777777777777) {
+					break
+				}
+			}
+		}
+	}
+}
+
+// doorkeeperHashes is the number of bits set per key in the doorkeeper.
+const doorkeeperHashes = 4
+
+// doorkeeper is a Bloom filter of the keys accessed since the last aging.
+// The bits are set with atomic operations.
+type doorkeeper struct {
+	bits []atomic.Uint64
+	mask uint64
+}
+
+func newDoorkeeper(samples int) doorkeeper {
+	// 4 bits per key recorded until the next aging keeps the false positive rate
+	// below 16% at worst, and around 5% on average between two agings
+	m := nextPowerOfTwo(max(samples*4, 64))
+	return doorkeeper{
+		bits: make([]atomic.Uint64, m/64),
+		mask: uint64(m - 1),
+	}
+}
+
+// add adds the hash to the filter and reports whether it was already in it.
+func (d *doorkeeper) add(h uint64) bool {
+	found := true
+	for i := range doorkeeperHashes {
+		bit := (h + uint64(i)*(h>>32|1)) & d.mask
+		if d.bits[bit/64].Or(1<<(bit%64))&(1<<(bit%64)) == 0 {
+			found = false
+		}
+	}
+	return found
+}
+
+func (d *doorkeeper) contains(h uint64) bool {
+	for i := range doorkeeperHashes {
+		bit := (h + uint64(i)*(h>>32|1)) & d.mask
+		if d.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *doorkeeper) clear() {
+	for i := range d.bits {
+		d.bits[i].Store(0)
+	}
+}
+
+func nextPowerOfTwo(n int) int {
+	return 1 << bits.Len(uint(n-1))
+}
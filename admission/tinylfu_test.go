@@ -0,0 +1,113 @@
+package admission
+
+import (
+	"hash/maphash"
+	"sync"
+	"testing"
+
+	"fortio.org/assert"
+)
+
+func TestEstimate(t *testing.T) {
+	tinylfu := NewTinyLFU[int](100)
+
+	assert.Equal(t, 0, tinylfu.Estimate(1))
+
+	// the first access only goes to the doorkeeper
+	tinylfu.Record(1)
+	assert.Equal(t, 1, tinylfu.Estimate(1))
+
+	for i := 0; i < 4; i++ {
+		tinylfu.Record(1)
+	}
+	assert.Equal(t, 5, tinylfu.Estimate(1))
+
+	// the counters saturate
+	for i := 0; i < 100; i++ {
+		tinylfu.Record(2)
+	}
+	assert.Equal(t, maxCount+1, tinylfu.Estimate(2))
+}
+
+func TestAdmit(t *testing.T) {
+	tinylfu := NewTinyLFU[int](100)
+
+	for i := 0; i < 3; i++ {
+		tinylfu.Record(1)
+	}
+	tinylfu.Record(2)
+
+	assert.True(t, tinylfu.Admit(1, 2))
+	assert.False(t, tinylfu.Admit(2, 1))
+	assert.False(t, tinylfu.Admit(3, 1))
+
+	// a candidate as frequent as the victim is admitted
+	tinylfu.Record(3)
+	assert.True(t, tinylfu.Admit(3, 2))
+}
+
+func TestAging(t *testing.T) {
+	tinylfu := NewTinyLFU[int](10)
+
+	for i := 0; i < 9; i++ {
+		tinylfu.Record(1)
+	}
+	assert.Equal(t, 9, tinylfu.Estimate(1))
+
+	// the frequencies are halved every samplesPerSize*size accesses
+	for i := 0; i < samplesPerSize*10-9; i++ {
+		tinylfu.Record(1000 + i)
+	}
+	assert.Equal(t, 4, tinylfu.Estimate(1))
+}
+
+func TestConcurrentRecord(t *testing.T) {
+	tinylfu := NewTinyLFU[int](1000)
+	tinylfu.Record(0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < maxCount-1; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tinylfu.Record(0)
+			tinylfu.Admit(0, g)
+		}()
+	}
+	wg.Wait()
+
+	// no increment is lost without a lock
+	assert.Equal(t, maxCount, tinylfu.Estimate(0))
+	assert.Equal(t, int64(maxCount), tinylfu.samples.Load())
+}
+
+func TestHalve(t *testing.T) {
+	s := newSketch(16)
+	for i := 0; i < maxCount; i++ {
+		s.increment(0)
+		s.increment(1)
+	}
+	s.increment(2)
+
+	s.halve()
+	assert.Equal(t, maxCount/2, s.estimate(0))
+	assert.Equal(t, maxCount/2, s.estimate(1))
+	assert.Equal(t, 0, s.estimate(2))
+}
+
+func TestDoorkeeperFalsePositives(t *testing.T) {
+	tinylfu := NewTinyLFU[int](100)
+
+	// the doorkeeper holds up to samplesPerSize*size keys until the next aging
+	for i := 0; i < samplesPerSize*100-1; i++ {
+		tinylfu.Record(i)
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if tinylfu.doorkeeper.contains(maphash.Comparable(tinylfu.seed, -1-i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 2000, "false positive rate above 20%")
+}
@@ -0,0 +1,43 @@
+// Package arc implements the ARC (Adaptive Replacement Cache) cache, balancing between
+// the keys accessed once and the ones accessed repeatedly as the workload changes.
+//
+// ARC remembers the keys it recently evicted, and a miss on one of them moves the balance
+// towards the list it was evicted from, so that it needs no tuning. Every hit takes the write lock.
+// See [ARC: A Self-Tuning, Low Overhead Replacement Cache] for the details.
+//
+// [ARC: A Self-Tuning, Low Overhead Replacement Cache]: https://www.usenix.org/conference/fast-03/arc-self-tuning-low-overhead-replacement-cache
+package arc
+
+import (
+	"time"
+
+	"github.com/scalalang2/golang-fifo/internal/cache"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// ARC is an adaptive replacement cache. Peek, Contains and iterating over the cache don't count as accesses.
+type ARC[K comparable, V any] struct {
+	*cache.Cache[K, V]
+}
+
+var (
+	_ types.StaleLoadingCache[int, int] = (*ARC[int, int])(nil)
+	_ types.BatchCache[int, int]        = (*ARC[int, int])(nil)
+	_ types.AtomicCache[int, int]       = (*ARC[int, int])(nil)
+)
+
+// New creates a cache holding up to size entries, with the given default TTL.
+// Zero ttl means no expiration. It panics if size is not positive.
+func New[K comparable, V any](size int, ttl time.Duration) *ARC[K, V] {
+	return &ARC[K, V]{cache.Must(cache.New("arc", size, newPolicy[K](), types.WithTTL[K, V](ttl)))}
+}
+
+// NewWithOptions creates a cache holding up to size entries, configured with the given options.
+// It returns an error if the options are invalid.
+func NewWithOptions[K comparable, V any](size int, opts ...types.Option[K, V]) (*ARC[K, V], error) {
+	c, err := cache.New("arc", size, newPolicy[K](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ARC[K, V]{c}, nil
+}
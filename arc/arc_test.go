@@ -0,0 +1,157 @@
+package arc
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+const noEvictionTTL = 0
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
+func TestARCPolicy(t *testing.T) {
+	cache := New[int, int](100, noEvictionTTL)
+	defer cache.Close()
+
+	// the popular keys are accessed twice
+	for i := 0; i < 50; i++ {
+		cache.Set(i, i)
+		cache.Get(i)
+	}
+
+	// a scan of keys accessed once doesn't flush them
+	for i := 1000; i < 2000; i++ {
+		cache.Set(i, i)
+	}
+	for i := 0; i < 50; i++ {
+		assert.True(t, cache.Contains(i))
+	}
+	assert.Equal(t, 100, cache.Len())
+}
+
+func TestPolicy(t *testing.T) {
+	p := newPolicy[int]()
+	p.Resize(4)
+	var evicted []int
+	evict := func(key int) {
+		evicted = append(evicted, key)
+	}
+	add := func(key int) {
+		if p.lists[t1].Len()+p.lists[t2].Len() == 4 {
+			p.Evict(&key, evict)
+		}
+		p.Add(key, 1)
+	}
+
+	for i := 1; i <= 4; i++ {
+		add(i)
+	}
+	p.Hit(1)
+	p.Hit(2)
+	assert.Equal(t, 2, p.lists[t1].Len())
+	assert.Equal(t, 2, p.lists[t2].Len())
+
+	// t1 is larger than its target, its tail becomes a ghost
+	add(5)
+	assert.Equal(t, []int{3}, evicted)
+	assert.Equal(t, b1, p.nodes[3].Value.(*node[int]).queue)
+
+	// a hit in b1 grows the target of t1, and admits the key in t2
+	add(3)
+	assert.Equal(t, int64(1), p.p)
+	assert.Equal(t, []int{3, 4}, evicted)
+	assert.Equal(t, t2, p.nodes[3].Value.(*node[int]).queue)
+
+	// t1 reached its target, the tail of t2 is evicted
+	add(6)
+	assert.Equal(t, []int{3, 4, 1}, evicted)
+	assert.Equal(t, b2, p.nodes[1].Value.(*node[int]).queue)
+
+	// a hit in b2 shrinks the target of t1
+	add(1)
+	assert.Equal(t, int64(0), p.p)
+	assert.Equal(t, []int{3, 4, 1, 5}, evicted)
+
+	// the ghost lists are bounded by the cache size
+	for i := 100; i < 200; i++ {
+		add(i)
+	}
+	ghosts := p.lists[b1].Len() + p.lists[b2].Len()
+	assert.True(t, ghosts <= 4)
+	assert.Equal(t, 4, p.lists[t1].Len()+p.lists[t2].Len())
+	assert.Equal(t, 4+ghosts, len(p.nodes))
+}
+
+func TestPolicyAfterRemove(t *testing.T) {
+	p := newPolicy[int]()
+	p.Resize(4)
+	var evicted []int
+	evict := func(key int) {
+		evicted = append(evicted, key)
+	}
+	add := func(key int) {
+		if p.lists[t1].Len()+p.lists[t2].Len() == 4 {
+			p.Evict(&key, evict)
+		}
+		p.Add(key, 1)
+	}
+
+	for i := 1; i <= 4; i++ {
+		add(i)
+	}
+	p.Hit(1)
+	add(5)
+	assert.Equal(t, []int{2}, evicted)
+
+	// a removed key leaves room, so a ghost hit doesn't evict
+	p.Remove(3)
+	add(2)
+	assert.Equal(t, []int{2}, evicted)
+	assert.Equal(t, t2, p.nodes[2].Value.(*node[int]).queue)
+}
+
+func TestPolicyInvariants(t *testing.T) {
+	const size = 16
+	p := newPolicy[int]()
+	p.Resize(size)
+	resident := make(map[int]bool)
+	evict := func(key int) {
+		delete(resident, key)
+	}
+	add := func(key int) {
+		if p.lists[t1].Len()+p.lists[t2].Len() == size {
+			p.Evict(&key, evict)
+		}
+		p.Add(key, 1)
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 100000; i++ {
+		key := rng.IntN(4 * size)
+		switch {
+		case resident[key] && rng.IntN(10) == 0:
+			p.Remove(key)
+			delete(resident, key)
+		case resident[key]:
+			p.Hit(key)
+		default:
+			add(key)
+			resident[key] = true
+		}
+
+		residents := p.lists[t1].Len() + p.lists[t2].Len()
+		assert.Equal(t, len(resident), residents)
+		assert.True(t, residents <= size)
+		assert.True(t, p.lists[t1].Len()+p.lists[b1].Len() <= size)
+		assert.True(t, residents+p.lists[b1].Len()+p.lists[b2].Len() <= 2*size)
+		assert.True(t, p.p >= 0 && p.p <= size)
+	}
+}
@@ -0,0 +1,204 @@
+package arc
+
+import "container/list"
+
+// queue is the list holding a key.
+type queue uint8
+
+const (
+	t1 queue = iota // resident keys accessed once recently
+	t2              // resident keys accessed at least twice recently
+	b1              // ghost keys evicted from t1
+	b2              // ghost keys evicted from t2
+)
+
+// node is a key in one of the lists.
+type node[K comparable] struct {
+	key    K
+	weight int64
+	queue  queue
+}
+
+// policy is the ARC eviction policy.
+//
+// The resident keys are split between t1, holding the keys accessed once recently,
+// and t2, holding the keys accessed at least twice. The keys evicted from t1 and t2
+// are remembered in the ghost lists b1 and b2. A new key hitting a ghost list
+// adapts the target size p of t1: a hit in b1 means t1 was too small and grows p,
+// a hit in b2 means t2 was too small and shrinks p. The evicted key is taken
+// from t1 while it's larger than p, and from t2 otherwise.
+//
+// The sizes of the lists are counted in weight, the ghost keys keeping the weight they had.
+type policy[K comparable] struct {
+	nodes   map[K]*list.Element
+	lists   [4]*list.List
+	weights [4]int64
+
+	// size is the capacity of the cache, and also bounds the weight of the ghost keys
+	size int64
+
+	// p is the target size of t1
+	p int64
+
+	// promoted is the ghost key the last evictions made room for, already taken out of its ghost list
+	// so that it's not forgotten by the evictions, and admitted in t2 by the next call to Add
+	promoted    K
+	promotedB2  bool
+	hasPromoted bool
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	p := &policy[K]{
+		nodes: make(map[K]*list.Element),
+	}
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+	return p
+}
+
+func (p *policy[K]) Add(key K, weight int64) bool {
+	ghost := p.hasPromoted && p.promoted == key
+	p.hasPromoted = false
+
+	if el, ok := p.nodes[key]; ok {
+		// the key is a ghost, and the cache had room for it without evicting
+		p.p = p.target(el.Value.(*node[K]).queue)
+		p.remove(el)
+		ghost = true
+	}
+	if ghost {
+		p.push(key, weight, t2)
+		return true
+	}
+
+	// the ghost lists are bounded by the size of the cache
+	for p.lists[b1].Len() > 0 && p.weights[t1]+p.weights[b1]+weight > p.size {
+		p.pop(b1)
+	}
+	for p.lists[b2].Len() > 0 && p.total()+weight > 2*p.size {
+		p.pop(b2)
+	}
+	p.push(key, weight, t1)
+	return false
+}
+
+func (p *policy[K]) Hit(key K) {
+	el := p.nodes[key]
+	n := el.Value.(*node[K])
+	p.remove(el)
+	p.push(key, n.weight, t2)
+}
+
+func (p *policy[K]) Update(key K, weight int64) {
+	n := p.nodes[key].Value.(*node[K])
+	p.weights[n.queue] += weight - n.weight
+	n.weight = weight
+}
+
+func (p *policy[K]) Remove(key K) {
+	p.remove(p.nodes[key])
+}
+
+func (p *policy[K]) Evict(incoming *K, evict func(key K)) {
+	if incoming != nil && !(p.hasPromoted && p.promoted == *incoming) {
+		if el, ok := p.nodes[*incoming]; ok {
+			// the key is a ghost: adapt the target size of t1 once, before the first eviction
+			ghost := el.Value.(*node[K]).queue
+			p.p = p.target(ghost)
+			p.remove(el)
+			p.promoted, p.promotedB2, p.hasPromoted = *incoming, ghost == b2, true
+		}
+	}
+
+	from, ghost := p.from(p.p, p.hasPromoted && p.promotedB2)
+	key := p.pop(from)
+	p.push(key.key, key.weight, ghost)
+	evict(key.key)
+}
+
+// Victim returns the key the next eviction would remove, without adapting the target size of t1.
+func (p *policy[K]) Victim(incoming K) (key K, ok bool) {
+	if p.lists[t1].Len()+p.lists[t2].Len() == 0 {
+		return key, false
+	}
+
+	target, hitB2 := p.p, p.hasPromoted && p.promotedB2
+	if el, ok := p.nodes[incoming]; ok {
+		ghost := el.Value.(*node[K]).queue
+		target, hitB2 = p.target(ghost), ghost == b2
+	}
+
+	from, _ := p.from(target, hitB2)
+	return p.lists[from].Back().Value.(*node[K]).key, true
+}
+
+// from returns the list the next evicted key is taken from, and the ghost list it goes to.
+// The key is taken from t1 if it's larger than its target size, or equal to it
+// when the added key hit b2, and from t2 otherwise.
+func (p *policy[K]) from(target int64, hitB2 bool) (from, ghost queue) {
+	t1Weight := p.weights[t1]
+	if (p.lists[t1].Len() > 0 && (t1Weight > target || (hitB2 && t1Weight == target))) || p.lists[t2].Len() == 0 {
+		return t1, b1
+	}
+	return t2, b2
+}
+
+// target returns the target size of t1 adapted to a hit in the given ghost list.
+func (p *policy[K]) target(ghost queue) int64 {
+	// the keys weighing nothing still count, so that the ratio is defined
+	b1Weight, b2Weight := max(p.weights[b1], 1), max(p.weights[b2], 1)
+	if ghost == b1 {
+		return min(p.p+max(b2Weight/b1Weight, 1), p.size)
+	}
+	return max(p.p-max(b1Weight/b2Weight, 1), 0)
+}
+
+func (p *policy[K]) Resize(capacity int64) {
+	p.size = capacity
+	p.p = min(p.p, p.size)
+
+	// the resident keys are evicted by the cache, but the ghost keys must fit the new size
+	for p.lists[b1].Len() > 0 && p.weights[t1]+p.weights[b1] > p.size {
+		p.pop(b1)
+	}
+	for p.lists[b2].Len() > 0 && p.total() > 2*p.size {
+		p.pop(b2)
+	}
+}
+
+func (p *policy[K]) Reset() {
+	for _, l := range p.lists {
+		l.Init()
+	}
+	p.weights = [4]int64{}
+	clear(p.nodes)
+	p.p = 0
+	p.hasPromoted = false
+}
+
+// total returns the weight of the resident and ghost keys.
+func (p *policy[K]) total() int64 {
+	return p.weights[t1] + p.weights[t2] + p.weights[b1] + p.weights[b2]
+}
+
+// push adds the key at the front of the list.
+func (p *policy[K]) push(key K, weight int64, q queue) {
+	p.nodes[key] = p.lists[q].PushFront(&node[K]{key: key, weight: weight, queue: q})
+	p.weights[q] += weight
+}
+
+// pop removes the key at the tail of the list, which must not be empty.
+func (p *policy[K]) pop(q queue) *node[K] {
+	el := p.lists[q].Back()
+	p.remove(el)
+	return el.Value.(*node[K])
+}
+
+// remove removes the key of the element from its list.
+func (p *policy[K]) remove(el *list.Element) {
+	n := el.Value.(*node[K])
+	p.lists[n.queue].Remove(el)
+	p.weights[n.queue] -= n.weight
+	delete(p.nodes, n.key)
+}
@@ -1,10 +1,20 @@
 package golang_fifo
 
 import (
+	"math/rand/v2"
+	"runtime"
 	"strconv"
 	"testing"
 
+	"github.com/scalalang2/golang-fifo/arc"
+	"github.com/scalalang2/golang-fifo/clock"
+	"github.com/scalalang2/golang-fifo/lirs"
+	"github.com/scalalang2/golang-fifo/lru"
+	"github.com/scalalang2/golang-fifo/s3fifo"
+	"github.com/scalalang2/golang-fifo/sharded"
 	"github.com/scalalang2/golang-fifo/sieve"
+	"github.com/scalalang2/golang-fifo/tinylfu"
+	"github.com/scalalang2/golang-fifo/types"
 )
 
 type value struct {
@@ -83,3 +93,105 @@ func benchmarkSieveCache[T benchTypes](b *testing.B, cacheSize int, genKey func(
 	}
 	cache.Purge()
 }
+
+func BenchmarkCacheParallel(b *testing.B) {
+	cacheSize := 100000
+	shards := runtime.GOMAXPROCS(0) * 4
+
+	newSieve := func(size int) types.Cache[int64, value] {
+		return sieve.New[int64, value](size, 0)
+	}
+	newS3FIFO := func(size int) types.Cache[int64, value] {
+		return s3fifo.New[int64, value](size, 0)
+	}
+	newTinyLFU := func(size int) types.Cache[int64, value] {
+		return tinylfu.New[int64, value](size, 0)
+	}
+	newARC := func(size int) types.Cache[int64, value] {
+		return arc.New[int64, value](size, 0)
+	}
+	newLRU := func(size int) types.Cache[int64, value] {
+		return lru.New[int64, value](size, 0)
+	}
+	newClock := func(size int) types.Cache[int64, value] {
+		return clock.New[int64, value](size, 0)
+	}
+	newLIRS := func(size int) types.Cache[int64, value] {
+		return lirs.New[int64, value](size, 0)
+	}
+
+	b.Run("cache=sieve", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newSieve(cacheSize))
+	})
+	b.Run("cache=sharded-sieve", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newSieve))
+	})
+	b.Run("cache=s3fifo", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newS3FIFO(cacheSize))
+	})
+	b.Run("cache=sharded-s3fifo", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newS3FIFO))
+	})
+	b.Run("cache=tinylfu", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newTinyLFU(cacheSize))
+	})
+	b.Run("cache=sharded-tinylfu", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newTinyLFU))
+	})
+	b.Run("cache=arc", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newARC(cacheSize))
+	})
+	b.Run("cache=sharded-arc", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newARC))
+	})
+	b.Run("cache=lru", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newLRU(cacheSize))
+	})
+	b.Run("cache=sharded-lru", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newLRU))
+	})
+	b.Run("cache=clock", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newClock(cacheSize))
+	})
+	b.Run("cache=sharded-clock", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newClock))
+	})
+	b.Run("cache=lirs", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, newLIRS(cacheSize))
+	})
+	b.Run("cache=sharded-lirs", func(b *testing.B) {
+		benchmarkParallel(b, cacheSize, sharded.New[int64, value](cacheSize, shards, newLIRS))
+	})
+}
+
+// benchmarkParallel runs a read-heavy workload with 10% of writes,
+// where the keys are twice as many as the cache size.
+// It reports the hit ratio of the caches providing statistics.
+func benchmarkParallel(b *testing.B, cacheSize int, cache types.Cache[int64, value]) {
+	workload := int64(cacheSize * 2)
+	for i := int64(0); i < int64(cacheSize); i++ {
+		cache.Set(i, value{bytes: make([]byte, 10)})
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for pb.Next() {
+			key := rng.Int64N(workload)
+			if rng.IntN(10) == 0 {
+				cache.Set(key, value{bytes: make([]byte, 10)})
+			} else {
+				cache.Get(key)
+			}
+		}
+	})
+	b.StopTimer()
+
+	if s, ok := cache.(interface{ Stats() types.Stats }); ok {
+		stats := s.Stats()
+		if gets := stats.Hits + stats.Misses; gets > 0 {
+			b.ReportMetric(float64(stats.Hits)/float64(gets), "hit-ratio")
+		}
+	}
+	cache.Close()
+}
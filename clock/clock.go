@@ -0,0 +1,41 @@
+// Package clock implements the CLOCK cache, an approximation of LRU setting a reference bit on hits,
+// as a baseline to compare the eviction policies of the other packages with.
+//
+// The keys sit in a circular buffer, and the hand gives the referenced keys a second chance
+// before evicting them. Unlike LRU, a hit doesn't move the key, but it still takes the write lock.
+package clock
+
+import (
+	"time"
+
+	"github.com/scalalang2/golang-fifo/internal/cache"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Clock is a CLOCK cache. Peek, Contains and iterating over the cache
+// don't set the reference bit of the entries.
+type Clock[K comparable, V any] struct {
+	*cache.Cache[K, V]
+}
+
+var (
+	_ types.StaleLoadingCache[int, int] = (*Clock[int, int])(nil)
+	_ types.BatchCache[int, int]        = (*Clock[int, int])(nil)
+	_ types.AtomicCache[int, int]       = (*Clock[int, int])(nil)
+)
+
+// New creates a cache holding up to size entries, with the given default TTL.
+// Zero ttl means no expiration. It panics if size is not positive.
+func New[K comparable, V any](size int, ttl time.Duration) *Clock[K, V] {
+	return &Clock[K, V]{cache.Must(cache.New("clock", size, newPolicy[K](), types.WithTTL[K, V](ttl)))}
+}
+
+// NewWithOptions creates a cache holding up to size entries, configured with the given options.
+// It returns an error if the options are invalid.
+func NewWithOptions[K comparable, V any](size int, opts ...types.Option[K, V]) (*Clock[K, V], error) {
+	c, err := cache.New("clock", size, newPolicy[K](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Clock[K, V]{c}, nil
+}
@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+const noEvictionTTL = 0
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
+func TestClockPolicy(t *testing.T) {
+	cache := New[int, int](3, noEvictionTTL)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+
+	// the referenced key gets a second chance, the hand evicts the next one
+	cache.Get(1)
+	cache.Set(4, 4)
+	assert.False(t, cache.Contains(2))
+
+	// the reference bit of 1 was cleared, and the hand moved past 4
+	cache.Set(5, 5)
+	assert.False(t, cache.Contains(3))
+	cache.Set(6, 6)
+	assert.False(t, cache.Contains(1))
+	assert.True(t, cache.Contains(4))
+	assert.True(t, cache.Contains(5))
+	assert.True(t, cache.Contains(6))
+}
+
+func TestPeekDoesNotUpdateRecency(t *testing.T) {
+	cache := New[int, int](3, noEvictionTTL)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+
+	value, ok := cache.Peek(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.True(t, cache.Contains(1))
+
+	cache.Set(4, 4)
+	assert.False(t, cache.Contains(1))
+	assert.True(t, cache.Contains(2))
+}
+
+func TestPolicyReusesFreeSlots(t *testing.T) {
+	p := newPolicy[int]()
+	var evicted []int
+	evict := func(key int) {
+		evicted = append(evicted, key)
+	}
+	add := func(key int) {
+		if len(p.index) == 3 {
+			p.Evict(&key, evict)
+		}
+		p.Add(key, 1)
+	}
+
+	for i := 1; i <= 3; i++ {
+		add(i)
+	}
+
+	// a removed key frees its slot, so adding a key doesn't evict
+	p.Remove(2)
+	add(4)
+	assert.Equal(t, 0, len(evicted))
+	assert.Equal(t, 1, p.index[4])
+	assert.Equal(t, 3, len(p.slots))
+
+	add(5)
+	assert.Equal(t, []int{1}, evicted)
+	assert.Equal(t, 3, len(p.index))
+}
@@ -0,0 +1,112 @@
+package clock
+
+// slot holds a key of the clock.
+type slot[K comparable] struct {
+	key        K
+	used       bool
+	referenced bool
+}
+
+// policy is the CLOCK eviction policy, an approximation of LRU.
+//
+// The keys sit in a circular buffer of slots, each with a reference bit set by a hit.
+// To evict a key, the hand sweeps the slots, clearing the reference bits it passes,
+// and evicts the first key whose bit is already clear. The next key takes its slot.
+// The buffer grows when every slot is used, as the number of keys depends on their weights.
+type policy[K comparable] struct {
+	slots []slot[K]
+	index map[K]int
+
+	// free holds the indexes of the unused slots, used as a stack
+	free []int
+
+	// hand is the index of the next slot to examine
+	hand int
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	return &policy[K]{
+		index: make(map[K]int),
+	}
+}
+
+func (p *policy[K]) Add(key K, _ int64) bool {
+	i := len(p.slots)
+	if n := len(p.free); n > 0 {
+		i = p.free[n-1]
+		p.free = p.free[:n-1]
+	} else {
+		p.slots = append(p.slots, slot[K]{})
+	}
+
+	p.slots[i] = slot[K]{key: key, used: true}
+	p.index[key] = i
+	return false
+}
+
+func (p *policy[K]) Hit(key K) {
+	p.slots[p.index[key]].referenced = true
+}
+
+func (p *policy[K]) Update(K, int64) {}
+
+func (p *policy[K]) Remove(key K) {
+	i := p.index[key]
+	p.slots[i] = slot[K]{}
+	delete(p.index, key)
+	p.free = append(p.free, i)
+}
+
+func (p *policy[K]) Evict(_ *K, evict func(key K)) {
+	for !p.slots[p.hand].used || p.slots[p.hand].referenced {
+		p.slots[p.hand].referenced = false
+		p.advance()
+	}
+
+	key := p.slots[p.hand].key
+	p.Remove(key)
+	p.advance()
+	evict(key)
+}
+
+// Victim returns the key the next eviction would remove, without clearing the reference bits.
+func (p *policy[K]) Victim(K) (key K, ok bool) {
+	if len(p.index) == 0 {
+		return key, false
+	}
+
+	first := -1
+	for n, i := 0, p.hand; n < len(p.slots); n++ {
+		if s := p.slots[i]; s.used {
+			if !s.referenced {
+				return s.key, true
+			}
+			if first < 0 {
+				first = i
+			}
+		}
+		if i++; i == len(p.slots) {
+			i = 0
+		}
+	}
+
+	// every key is referenced, the eviction clears them all and comes back to the first one
+	return p.slots[first].key, true
+}
+
+// advance moves the hand to the next slot.
+func (p *policy[K]) advance() {
+	p.hand++
+	if p.hand == len(p.slots) {
+		p.hand = 0
+	}
+}
+
+func (p *policy[K]) Resize(int64) {}
+
+func (p *policy[K]) Reset() {
+	p.slots = p.slots[:0]
+	p.free = p.free[:0]
+	clear(p.index)
+	p.hand = 0
+}
@@ -0,0 +1,73 @@
+// Package expvarobserver is an example of adapter from [types.Observer] to a metrics library,
+// publishing the events of a cache as [expvar] counters.
+//
+//	cache, err := sieve.NewWithOptions[string, string](size,
+//		types.WithObserver[string, string](expvarobserver.Publish("users_cache")),
+//	)
+//
+// An adapter for another library, such as OpenTelemetry, follows the same shape:
+// it increments its instruments in the methods of the observer, which must be fast.
+package expvarobserver
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Observer counts the events of a cache in an [expvar.Map].
+type Observer struct {
+	vars *expvar.Map
+}
+
+var _ types.Observer = (*Observer)(nil)
+
+// New creates an observer whose counters are not published.
+func New() *Observer {
+	return &Observer{vars: new(expvar.Map).Init()}
+}
+
+// Publish creates an observer and publishes its counters under the given expvar name.
+// Like [expvar.Publish], it panics if the name is already in use.
+func Publish(name string) *Observer {
+	o := New()
+	expvar.Publish(name, o.vars)
+	return o
+}
+
+// Vars returns the counters of the observer.
+func (o *Observer) Vars() *expvar.Map {
+	return o.vars
+}
+
+func (o *Observer) OnGet(hit bool) {
+	if hit {
+		o.vars.Add("hits", 1)
+	} else {
+		o.vars.Add("misses", 1)
+	}
+}
+
+func (o *Observer) OnSet(update bool) {
+	if update {
+		o.vars.Add("updates", 1)
+	} else {
+		o.vars.Add("sets", 1)
+	}
+}
+
+func (o *Observer) OnEvict(reason types.EvictReason) {
+	o.vars.Add("evictions_"+reason.String(), 1)
+}
+
+func (o *Observer) OnExpireSweep(count int, duration time.Duration) {
+	o.vars.Add("expire_sweeps", 1)
+	o.vars.Add("expire_swept", int64(count))
+	o.vars.Add("expire_sweep_ns", duration.Nanoseconds())
+}
+
+func (o *Observer) OnLockWait(wait time.Duration) {
+	o.vars.Add("lock_waits", 1)
+	o.vars.Add("lock_wait_ns", wait.Nanoseconds())
+}
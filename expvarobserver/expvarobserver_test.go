@@ -0,0 +1,55 @@
+package expvarobserver
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/sieve"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+func value(o *Observer, name string) string {
+	v := o.Vars().Get(name)
+	if v == nil {
+		return "0"
+	}
+	return v.String()
+}
+
+// published counts the observers published by the tests, to give them unique names.
+var published atomic.Int32
+
+func TestPublish(t *testing.T) {
+	name := fmt.Sprintf("expvarobserver_test_%d", published.Add(1))
+	observer := Publish(name)
+	assert.Equal(t, expvar.Var(observer.Vars()), expvar.Get(name))
+}
+
+func TestObserver(t *testing.T) {
+	observer := New()
+
+	cache, err := sieve.NewWithOptions[int, int](2,
+		types.WithObserver[int, int](observer),
+	)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(1, 10)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+	cache.Get(3)
+	cache.Get(2)
+	cache.Remove(3)
+
+	assert.Equal(t, "3", value(observer, "sets"))
+	assert.Equal(t, "1", value(observer, "updates"))
+	assert.Equal(t, "1", value(observer, "hits"))
+	assert.Equal(t, "1", value(observer, "misses"))
+	assert.Equal(t, "1", value(observer, "evictions_evicted"))
+	assert.Equal(t, "1", value(observer, "evictions_removed"))
+	assert.True(t, value(observer, "lock_waits") != "0")
+}
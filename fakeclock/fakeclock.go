@@ -0,0 +1,143 @@
+// Package fakeclock provides a manual [types.Clock] for testing,
+// whose time only moves forward when Advance is called.
+package fakeclock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Clock is a fake clock that is advanced manually.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*Ticker
+	timers  []*timer
+}
+
+var _ types.Clock = (*Clock)(nil)
+
+// timer is a pending channel created by After.
+type timer struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+// New creates a fake clock starting at the given time.
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTicker returns a ticker that ticks every d as the clock is advanced.
+// Like [time.Ticker], ticks are dropped if the receiver is not keeping up.
+func (c *Clock) NewTicker(d time.Duration) types.Ticker {
+	if d <= 0 {
+		panic("fakeclock: non-positive interval for NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &Ticker{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// After returns a channel receiving the time once the clock is advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{
+		deadline: c.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		t.c <- c.now
+		return t.c
+	}
+	c.timers = append(c.timers, t)
+	return t.c
+}
+
+// Advance moves the clock forward by d, firing the tickers and timers due in the meantime.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	for _, t := range c.tickers {
+		t.fire(c.now)
+	}
+
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = timers
+}
+
+// Ticker is a ticker created by a fake [Clock].
+// Its fields are guarded by the lock of the clock.
+type Ticker struct {
+	clock  *Clock
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+// C returns the channel on which the ticks are delivered.
+func (t *Ticker) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop turns off the ticker, and releases it from the clock.
+func (t *Ticker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, ticker := range c.tickers {
+		if ticker == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// fire delivers a tick if the next tick is due at now. It's called with the lock of the clock held.
+func (t *Ticker) fire(now time.Time) {
+	if t.next.After(now) {
+		return
+	}
+
+	// skip the ticks missed while advancing the clock at once
+	for !t.next.After(now) {
+		t.next = t.next.Add(t.period)
+	}
+
+	select {
+	case t.c <- now:
+	default:
+	}
+}
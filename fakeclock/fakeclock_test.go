@@ -0,0 +1,79 @@
+package fakeclock
+
+import (
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+)
+
+func TestAdvance(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := New(start)
+	assert.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+}
+
+func TestTicker(t *testing.T) {
+	clock := New(time.Unix(0, 0))
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired too early")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case now := <-ticker.C():
+		assert.Equal(t, clock.Now(), now)
+	default:
+		t.Fatal("ticker did not fire")
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+
+	// stopping twice is a no-op
+	ticker.Stop()
+}
+
+func TestStoppedTickersAreReleased(t *testing.T) {
+	clock := New(time.Unix(0, 0))
+	for i := 0; i < 10; i++ {
+		clock.NewTicker(time.Second).Stop()
+	}
+	ticker := clock.NewTicker(time.Second)
+	assert.Equal(t, 1, len(clock.tickers))
+
+	ticker.Stop()
+	assert.Equal(t, 0, len(clock.tickers))
+}
+
+func TestAfter(t *testing.T) {
+	clock := New(time.Unix(0, 0))
+	c := clock.After(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-c:
+		t.Fatal("timer fired too early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case <-c:
+	default:
+		t.Fatal("timer did not fire")
+	}
+}
@@ -0,0 +1,1076 @@
+// Package cache provides the cache shared by the packages implementing an eviction policy,
+// such as sieve or lru. It handles the entries, their weight and TTL, the loads, the eviction callbacks
+// and the statistics, and leaves the choice of the entries to evict to a [Policy].
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scalalang2/golang-fifo/internal/dispatch"
+	"github.com/scalalang2/golang-fifo/internal/negative"
+	"github.com/scalalang2/golang-fifo/internal/singleflight"
+	"github.com/scalalang2/golang-fifo/internal/stats"
+	"github.com/scalalang2/golang-fifo/internal/timerwheel"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// ticksPerTTL is the number of ticks of the expiration wheel in the default TTL
+const ticksPerTTL = 100
+
+// defaultCleanupInterval is the interval between two ticks of the expiration wheel
+// when the cache has no default TTL but entries are set with their own TTL.
+const defaultCleanupInterval = time.Second
+
+// entry holds the key and value of a cache entry.
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	weight    int64
+	expiredAt time.Time // expiredAt is zero if the entry never expires
+	ttl       time.Duration
+	timer     timerwheel.Timer[K]
+
+	// refreshing is set without the write lock when a read starts reloading the entry
+	refreshing atomic.Bool
+}
+
+// Cache is a cache holding up to size entries, or up to a total weight of entries, evicted by a Policy.
+type Cache[K comparable, V any] struct {
+	// name prefixes the errors and panics, as the name of the package built on the cache
+	name string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.RWMutex
+	size   int
+	items  map[K]*entry[K, V]
+	policy Policy[K]
+
+	// visitor records the hits under the read lock; nil if the policy records them under the write lock
+	visitor Visitor[K]
+
+	// evict is the method value of evicted passed to the policy, to allocate it once
+	evict func(key K)
+
+	// wheel holds the timers of the entries to be expired
+	wheel *timerwheel.Wheel[K]
+
+	// weigher returns the weight of an entry; if nil, every entry weighs 1
+	weigher types.Weigher[K, V]
+
+	// maxWeight is the maximum total weight of the entries when weigher is set
+	maxWeight int64
+
+	// weight is the current total weight of the entries
+	weight int64
+
+	// ttl is the default time to live of the cache entry
+	ttl time.Duration
+
+	// grace is the time an expired entry is retained to be served as stale when its reload fails
+	grace time.Duration
+
+	// clock provides the current time
+	clock types.Clock
+
+	// interval is the time between two ticks of the expiration wheel
+	interval time.Duration
+
+	// cleanupStarted reports whether the cleanup goroutine is running
+	cleanupStarted bool
+
+	// callback is the function that will be called when an entry is evicted from the cache
+	callback types.OnEvictCallback[K, V]
+
+	// evictions holds the entries evicted while the lock is held, to be passed to callback once it's released
+	evictions []dispatch.Eviction[K, V]
+
+	// dispatcher calls callback outside the lock
+	dispatcher *dispatch.Dispatcher[K, V]
+
+	// refresh reloads the entries read within refreshFraction of their expiration; if nil, entries are never refreshed
+	refresh types.Loader[K, V]
+
+	// refreshFraction is the fraction of the TTL of an entry, before its expiration, in which a read refreshes it
+	refreshFraction float64
+
+	// negatives holds the failed loads cached apart from the entries; nil if negative caching is disabled
+	negatives *negative.Cache[K]
+
+	// notFoundTTL and errorTTL are the times the failed loads are cached
+	notFoundTTL time.Duration
+	errorTTL    time.Duration
+
+	// loads coalesces concurrent loads of the same key
+	loads singleflight.Group[K, V]
+
+	// admission decides whether a new key is set when the cache is full; nil if every key is set
+	admission types.Admission[K]
+
+	// stats counts the cache events
+	stats stats.Counter
+
+	// observer is notified of the events of the cache; nil if it's the no-op observer
+	observer types.Observer
+}
+
+// New creates a cache holding up to size entries evicted by the policy, configured with the given options.
+// The name of the package built on the cache prefixes its errors and panics.
+// It returns an error if the options are invalid.
+func New[K comparable, V any](name string, size int, policy Policy[K], opts ...types.Option[K, V]) (*Cache[K, V], error) {
+	o, err := types.NewOptions(size, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	interval := o.CleanupInterval
+	if interval == 0 {
+		interval = defaultCleanupInterval
+		if o.TTL != 0 {
+			interval = max(o.TTL/ticksPerTTL, 1)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cache := &Cache[K, V]{
+		name:            name,
+		ctx:             ctx,
+		cancel:          cancel,
+		size:            size,
+		items:           make(map[K]*entry[K, V]),
+		policy:          policy,
+		wheel:           timerwheel.New[K](interval, o.Clock.Now()),
+		weigher:         o.Weigher,
+		maxWeight:       o.MaxWeight,
+		ttl:             o.TTL,
+		grace:           o.StaleGrace,
+		notFoundTTL:     o.NotFoundTTL,
+		errorTTL:        o.ErrorTTL,
+		clock:           o.Clock,
+		interval:        interval,
+		callback:        o.OnEvicted,
+		dispatcher:      dispatch.New[K, V](o.EvictionWorkers, o.EvictionBuffer, o.OnCallbackPanic),
+		refresh:         o.RefreshLoader,
+		refreshFraction: o.RefreshAhead,
+		admission:       o.Admission,
+	}
+	cache.evict = cache.evicted
+	cache.visitor, _ = policy.(Visitor[K])
+	policy.Resize(cache.capacity())
+
+	if o.Observer != (types.NoopObserver{}) {
+		cache.observer = o.Observer
+		cache.stats.Observe(o.Observer)
+	}
+
+	if o.NegativeSize > 0 {
+		cache.negatives = negative.New[K](o.NegativeSize)
+	}
+
+	if o.TTL != 0 {
+		cache.startCleanup()
+	}
+
+	return cache, nil
+}
+
+// Must returns the cache created by New, and panics if it returned an error.
+func Must[K comparable, V any](cache *Cache[K, V], err error) *Cache[K, V] {
+	if err != nil {
+		panic(err.Error())
+	}
+	return cache
+}
+
+// Inspect calls fn with the read lock of the cache held,
+// for the packages built on the cache to read the state of their policy.
+func Inspect[K comparable, V any](c *Cache[K, V], fn func()) {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	fn()
+}
+
+// startCleanup runs the cleanup goroutine if it is not running yet.
+// It must be called with the lock held or before the cache is shared.
+func (c *Cache[K, V]) startCleanup() {
+	if c.cleanupStarted {
+		return
+	}
+	c.cleanupStarted = true
+
+	// the ticker is created before returning, so that advancing the clock right after
+	// setting an entry fires it, instead of racing with the start of the goroutine
+	go c.cleanup(c.ctx, c.clock.NewTicker(c.interval))
+}
+
+func (c *Cache[K, V]) cleanup(ctx context.Context, ticker types.Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.DeleteExpired()
+		}
+	}
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL sets the value for the given key with its own time to live,
+// overriding the default TTL of the cache.
+// If ttl is zero or negative, the entry never expires.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.lock()
+	defer c.unlock()
+
+	c.set(key, value, ttl, true)
+}
+
+// SetMany sets the given entries on cache in order, under a single lock.
+func (c *Cache[K, V]) SetMany(entries []types.Entry[K, V]) {
+	c.lock()
+	defer c.unlock()
+
+	for _, e := range entries {
+		c.set(e.Key, e.Value, c.ttl, true)
+	}
+}
+
+// set sets the value for the key. If record is true and the key is new,
+// the access is recorded by the admission policy; it's false if the access was already recorded,
+// such as by the miss of a loading get, or if setting the value isn't an access.
+func (c *Cache[K, V]) set(key K, value V, ttl time.Duration, record bool) {
+	var expiredAt time.Time
+	if ttl > 0 {
+		expiredAt = c.clock.Now().Add(ttl)
+	}
+
+	// a value set for the key replaces its failed load
+	c.negatives.Remove(key)
+
+	if e, ok := c.items[key]; ok && c.expired(e) {
+		// the expired entry is replaced by a new one
+		c.removeEntry(e, types.EvictReasonExpired)
+	}
+
+	weight := c.weigh(key, value)
+	if weight > c.capacity() {
+		// the entry can never fit in the cache, so it's rejected
+		// and the previous value under the key is dropped.
+		if e, ok := c.items[key]; ok {
+			c.removeEntry(e, types.EvictReasonEvicted)
+		}
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.stats.Update()
+		c.policy.Hit(key)
+		c.update(e, value, weight, expiredAt, ttl)
+		return
+	}
+
+	if record && c.admission != nil {
+		c.admission.Record(key)
+	}
+
+	if c.weight+weight > c.capacity() {
+		// prefer reclaiming expired entries before evicting live ones
+		c.expireEntries()
+	}
+	if c.weight+weight > c.capacity() && !c.admit(key) {
+		c.stats.Reject()
+		return
+	}
+	for c.weight+weight > c.capacity() {
+		c.policy.Evict(&key, c.evict)
+	}
+
+	e := &entry[K, V]{
+		key:       key,
+		value:     value,
+		weight:    weight,
+		expiredAt: expiredAt,
+		ttl:       ttl,
+	}
+	c.items[key] = e
+	c.weight += weight
+	if c.policy.Add(key, weight) {
+		c.stats.GhostHit()
+	}
+	c.stats.Set()
+	c.schedule(e)
+}
+
+// Get gets the value for the given key from cache.
+// It only holds the read lock if the policy records the hits atomically.
+// An expired entry is never returned, and it's removed from the cache.
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	value, ok = c.lookup(key, true)
+	if ok {
+		c.stats.Hit()
+	} else {
+		c.stats.Miss()
+	}
+	return value, ok
+}
+
+// GetOrLoad gets the value for the given key from cache,
+// or loads it with the loader and sets it on cache if it is missing.
+// Concurrent calls missing the same key share a single loader call.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader types.Loader[K, V]) (value V, err error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	return c.load(ctx, key, loader)
+}
+
+// GetOrLoadStale works like GetOrLoad, but if the loader fails to reload an expired entry
+// still in its stale grace period, it returns the expired value with stale set and a nil error.
+// If ctx is done before the entry is reloaded, it returns the expired value with stale set
+// and the context error, so that the caller can tell it didn't wait for the reload.
+func (c *Cache[K, V]) GetOrLoadStale(ctx context.Context, key K, loader types.Loader[K, V]) (value V, stale bool, err error) {
+	if value, ok := c.Get(key); ok {
+		return value, false, nil
+	}
+
+	value, err = c.load(ctx, key, loader)
+	if err == nil {
+		return value, false, nil
+	}
+
+	// a key not found anymore has no value to serve
+	if errors.Is(err, types.ErrNotFound) {
+		return value, false, err
+	}
+	if value, ok := c.lookupStale(key); ok {
+		return value, true, ctx.Err()
+	}
+	return value, false, err
+}
+
+// load loads the value for the given key with the loader and sets it on cache.
+// It returns the cached failed load of the key without calling the loader, if any.
+func (c *Cache[K, V]) load(ctx context.Context, key K, loader types.Loader[K, V]) (V, error) {
+	if err := c.negative(key); err != nil {
+		var zero V
+		return zero, err
+	}
+
+	return c.loads.Do(ctx, key, func(ctx context.Context) (V, error) {
+		value, err := loader(ctx, key)
+		if err != nil {
+			c.setNegative(key, err)
+			return value, err
+		}
+		c.setLoaded(key, value)
+		return value, nil
+	})
+}
+
+// setLoaded sets the value loaded for the key, whose access was recorded by the miss.
+func (c *Cache[K, V]) setLoaded(key K, value V) {
+	c.lock()
+	defer c.unlock()
+
+	c.set(key, value, c.ttl, false)
+}
+
+// negative returns the cached failed load of the key, or nil if there is none.
+func (c *Cache[K, V]) negative(key K) error {
+	if c.negatives == nil {
+		return nil
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	err := c.negatives.Get(key, c.clock.Now())
+	if err != nil {
+		c.stats.NegativeHit()
+	}
+	return err
+}
+
+// setNegative caches the failed load of the key for the TTL of its error,
+// unless the key has been set in the meantime. Canceled loads are never cached.
+func (c *Cache[K, V]) setNegative(key K, err error) {
+	ttl := c.errorTTL
+	if errors.Is(err, types.ErrNotFound) {
+		ttl = c.notFoundTTL
+	}
+	if c.negatives == nil || ttl <= 0 || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	if e, ok := c.items[key]; ok && !c.expired(e) {
+		return
+	}
+	c.negatives.Set(key, err, c.clock.Now().Add(ttl))
+	c.stats.NegativeSet()
+}
+
+// lookupStale returns the value of the expired entry under the key if it's in its grace period.
+func (c *Cache[K, V]) lookupStale(key K) (value V, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	if e, found := c.items[key]; found && c.expired(e) && c.stale(e) {
+		return e.value, true
+	}
+	return value, false
+}
+
+// GetMany gets the values for the given keys from cache, under a single lock.
+// The returned map only holds the keys found in the cache.
+func (c *Cache[K, V]) GetMany(keys []K) map[K]V {
+	found := make(map[K]V, len(keys))
+	var expired []*entry[K, V]
+
+	c.lockHits()
+	for _, key := range keys {
+		if c.admission != nil {
+			c.admission.Record(key)
+		}
+
+		e, ok := c.items[key]
+		if !ok {
+			c.stats.Miss()
+			continue
+		}
+		if c.expired(e) {
+			if !c.stale(e) {
+				expired = append(expired, e)
+			}
+			c.stats.Miss()
+			continue
+		}
+		c.hit(key)
+		c.refreshAhead(e)
+		found[key] = e.value
+		c.stats.Hit()
+	}
+	c.unlockHits()
+
+	if len(expired) > 0 {
+		c.removeIfExpired(expired...)
+	}
+	return found
+}
+
+// GetOrSet returns the existing value for the key if present.
+// Otherwise, it sets and returns the given value. The loaded result is true if the value was present.
+func (c *Cache[K, V]) GetOrSet(key K, value V) (actual V, loaded bool) {
+	c.lock()
+	defer c.unlock()
+
+	if e, ok := c.find(key); ok {
+		c.policy.Hit(key)
+		c.stats.Hit()
+		return e.value, true
+	}
+
+	c.stats.Miss()
+	c.set(key, value, c.ttl, true)
+	return value, false
+}
+
+// Compute calls fn with the current value for the key, or ok=false if it's absent,
+// and applies the returned operation atomically. It returns the value for the key afterward.
+// An updated entry keeps its own TTL, and a new one gets the default TTL.
+// The fn is called under the lock of the cache, so it must not call the cache.
+func (c *Cache[K, V]) Compute(key K, fn func(old V, ok bool) (V, types.ComputeOp)) (value V, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	e, found := c.find(key)
+	var old V
+	if found {
+		old = e.value
+	}
+
+	value, op := fn(old, found)
+	switch op {
+	case types.ComputeUpdate:
+		ttl := c.ttl
+		if found {
+			ttl = e.ttl
+		}
+		c.set(key, value, ttl, true)
+		// the value is rejected if it's heavier than the cache capacity
+		_, ok = c.items[key]
+		return value, ok
+	case types.ComputeDelete:
+		if found {
+			c.removeEntry(e, types.EvictReasonRemoved)
+		}
+		var zero V
+		return zero, false
+	default:
+		if found {
+			c.policy.Hit(key)
+		}
+		return old, found
+	}
+}
+
+// CompareAndSwap sets the new value for the key if the current value is equal to old.
+// The entry keeps its own TTL. It panics if the type of the values is not comparable.
+func (c *Cache[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	c.lock()
+	defer c.unlock()
+
+	e, ok := c.find(key)
+	if !ok || any(e.value) != any(old) {
+		return false
+	}
+
+	c.set(key, new, e.ttl, true)
+	return true
+}
+
+func (c *Cache[K, V]) Remove(key K) (ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	return c.remove(key)
+}
+
+// RemoveMany removes the given keys from the cache in order, under a single lock.
+// The returned slice reports whether each key was removed, aligned with keys.
+func (c *Cache[K, V]) RemoveMany(keys []K) []bool {
+	c.lock()
+	defer c.unlock()
+
+	removed := make([]bool, len(keys))
+	for i, key := range keys {
+		removed[i] = c.remove(key)
+	}
+	return removed
+}
+
+func (c *Cache[K, V]) remove(key K) (ok bool) {
+	c.negatives.Remove(key)
+
+	if e, ok := c.find(key); ok {
+		c.removeEntry(e, types.EvictReasonRemoved)
+		return true
+	}
+
+	// drop the expired entry retained for its grace period
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e, types.EvictReasonExpired)
+	}
+	return false
+}
+
+// Contains reports whether the key is in the cache, without counting as an access.
+func (c *Cache[K, V]) Contains(key K) (ok bool) {
+	_, ok = c.lookup(key, false)
+	return
+}
+
+// Peek returns the value for the key, without counting as an access.
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	return c.lookup(key, false)
+}
+
+// lookup returns the value of the unexpired entry under the key, and records the access if visit is true.
+// It holds the read lock, unless it records the access with a policy without a Visitor.
+// An expired entry is removed from the cache.
+func (c *Cache[K, V]) lookup(key K, visit bool) (value V, ok bool) {
+	if visit && c.admission != nil {
+		c.admission.Record(key)
+	}
+
+	if visit {
+		c.lockHits()
+	} else {
+		c.rlock()
+	}
+	e, found := c.items[key]
+	expired := found && c.expired(e)
+	if found && !expired {
+		if visit {
+			c.hit(key)
+			c.refreshAhead(e)
+		}
+		value, ok = e.value, true
+	}
+	if visit {
+		c.unlockHits()
+	} else {
+		c.mu.RUnlock()
+	}
+
+	if expired && !c.stale(e) {
+		c.removeIfExpired(e)
+	}
+	return value, ok
+}
+
+// refreshAhead reloads the entry in the background if it's read close to its expiration,
+// unless it's already being reloaded. It's called with the read lock held.
+func (c *Cache[K, V]) refreshAhead(e *entry[K, V]) {
+	if c.refresh == nil || !c.refreshDue(e) || !e.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go c.reload(e, e.expiredAt)
+}
+
+// refreshDue reports whether the entry is within the refresh-ahead fraction of its TTL.
+func (c *Cache[K, V]) refreshDue(e *entry[K, V]) bool {
+	if e.expiredAt.IsZero() {
+		return false
+	}
+	ahead := time.Duration(float64(e.ttl) * c.refreshFraction)
+	return !c.clock.Now().Before(e.expiredAt.Add(-ahead))
+}
+
+// reload loads the value of the entry and replaces it, resetting the TTL of the entry,
+// unless the entry has been removed or updated since it was found due for refresh at expiredAt.
+// A failed reload leaves the entry as it is, to be refreshed by a later read.
+func (c *Cache[K, V]) reload(e *entry[K, V], expiredAt time.Time) {
+	value, err := c.loads.Do(c.ctx, e.key, func(ctx context.Context) (V, error) {
+		return c.refresh(ctx, e.key)
+	})
+
+	c.lock()
+	defer c.unlock()
+
+	e.refreshing.Store(false)
+	if err != nil || c.ctx.Err() != nil || c.items[e.key] != e || !e.expiredAt.Equal(expiredAt) {
+		return
+	}
+
+	// the reload is not an access to the entry: it's neither recorded as an update nor a hit
+	weight := c.weigh(e.key, value)
+	if weight > c.capacity() {
+		c.removeEntry(e, types.EvictReasonEvicted)
+		return
+	}
+	c.update(e, value, weight, c.clock.Now().Add(e.ttl), e.ttl)
+}
+
+// update replaces the value of the entry and its expiration, evicting entries if it grew.
+// It must be called with the write lock held.
+func (c *Cache[K, V]) update(e *entry[K, V], value V, weight int64, expiredAt time.Time, ttl time.Duration) {
+	c.unschedule(e) // unschedule the timer as the entry is updated
+	e.value = value
+	e.expiredAt = expiredAt
+	e.ttl = ttl
+	c.weight += weight - e.weight
+	e.weight = weight
+	c.policy.Update(e.key, weight)
+	c.schedule(e)
+
+	if c.weight > c.capacity() {
+		// prefer reclaiming expired entries before evicting live ones
+		c.expireEntries()
+	}
+	for c.weight > c.capacity() {
+		c.policy.Evict(nil, c.evict)
+	}
+}
+
+// find returns the unexpired entry under the key. It must be called with the write lock held.
+// An expired entry is treated as absent and removed from the cache, unless it's in its grace period.
+func (c *Cache[K, V]) find(key K) (e *entry[K, V], ok bool) {
+	e, ok = c.items[key]
+	if ok && c.expired(e) {
+		c.expire(e)
+		return nil, false
+	}
+	return e, ok
+}
+
+// removeIfExpired removes the entries found expired under the read lock,
+// unless they have been removed or updated in the meantime.
+func (c *Cache[K, V]) removeIfExpired(entries ...*entry[K, V]) {
+	c.lock()
+	defer c.unlock()
+
+	for _, e := range entries {
+		if c.items[e.key] == e && c.expired(e) {
+			c.expire(e)
+		}
+	}
+}
+
+// SetOnEvicted sets the callback called when an entry is evicted from the cache, once the cache lock is released.
+// A panic of the callback is recovered, so that it doesn't break the cache,
+// and reported to the handler set with [types.WithOnCallbackPanic], if any.
+func (c *Cache[K, V]) SetOnEvicted(callback types.OnEvictCallback[K, V]) {
+	c.lock()
+	defer c.mu.Unlock()
+
+	c.callback = callback
+}
+
+func (c *Cache[K, V]) Len() int {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// Resize changes the capacity of the cache and returns the number of entries evicted to fit in it.
+// Shrinking evicts entries through the eviction policy, calling the eviction callback with [types.EvictReasonEvicted].
+// If a weigher is set, newSize is the new maximum total weight of the entries.
+func (c *Cache[K, V]) Resize(newSize int) (evicted int) {
+	if newSize <= 0 {
+		panic(c.name + ": size must be greater than 0")
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	if c.weigher != nil {
+		c.maxWeight = int64(newSize)
+	} else {
+		c.size = newSize
+	}
+	c.policy.Resize(c.capacity())
+
+	n := len(c.items)
+	for c.weight > c.capacity() {
+		c.policy.Evict(nil, c.evict)
+	}
+
+	return n - len(c.items)
+}
+
+// Capacity returns the maximum number of entries in the cache,
+// or the maximum total weight of the entries if a weigher is set.
+func (c *Cache[K, V]) Capacity() int64 {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	return c.capacity()
+}
+
+// Weight returns the total weight of the entries in the cache.
+// It equals to Len if no weigher is set.
+func (c *Cache[K, V]) Weight() int64 {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	return c.weight
+}
+
+// SetWeigher bounds the cache by the total weight of its entries instead of the number of entries.
+// Entries are evicted until the total weight fits in maxWeight, and an entry heavier than maxWeight is never stored.
+// If weigher is nil, every entry weighs 1 and the cache is bounded by its size again.
+func (c *Cache[K, V]) SetWeigher(weigher types.Weigher[K, V], maxWeight int64) {
+	if weigher != nil && maxWeight <= 0 {
+		panic(c.name + ": max weight must be greater than 0")
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	c.weigher = weigher
+	c.maxWeight = maxWeight
+	c.policy.Resize(c.capacity())
+
+	// the total weight stays consistent with the entries if the weigher panics
+	for _, e := range c.items {
+		weight := c.weigh(e.key, e.value)
+		c.weight += weight - e.weight
+		e.weight = weight
+		c.policy.Update(e.key, e.weight)
+	}
+
+	for c.weight > c.capacity() {
+		c.policy.Evict(nil, c.evict)
+	}
+}
+
+// All returns an iterator over the key-value pairs in the cache.
+// The iterator yields a snapshot taken when the iteration starts,
+// so the cache can be safely modified while iterating.
+// Expired entries are skipped, and the iteration doesn't count as an access to the entries.
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		snapshot := c.snapshot()
+		for i := range snapshot {
+			if !yield(snapshot[i].key, snapshot[i].value) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys in the cache.
+// It has the same semantics as All.
+func (c *Cache[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range c.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values in the cache.
+// It has the same semantics as All.
+func (c *Cache[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range c.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns a copy of the unexpired entries in the cache.
+func (c *Cache[K, V]) snapshot() []entry[K, V] {
+	c.rlock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now()
+	snapshot := make([]entry[K, V], 0, len(c.items))
+	for _, e := range c.items {
+		if !e.expiredAt.IsZero() && !e.expiredAt.After(now) {
+			continue
+		}
+		snapshot = append(snapshot, entry[K, V]{key: e.key, value: e.value})
+	}
+	return snapshot
+}
+
+// Stats returns the statistics of the cache.
+// The counters are updated atomically, so they can be read at any time.
+func (c *Cache[K, V]) Stats() types.Stats {
+	return c.stats.Snapshot()
+}
+
+// ResetStats sets all the statistics of the cache to zero.
+func (c *Cache[K, V]) ResetStats() {
+	c.stats.Reset()
+}
+
+// Purge removes all the entries, calling the eviction callback with [types.EvictReasonRemoved] for each of them.
+func (c *Cache[K, V]) Purge() {
+	c.lock()
+	defer c.unlock()
+
+	for _, e := range c.items {
+		c.deleteEntry(e, types.EvictReasonRemoved)
+	}
+
+	c.policy.Reset()
+	c.wheel.Clear()
+	c.negatives.Clear()
+}
+
+func (c *Cache[K, V]) Close() {
+	c.Purge()
+	c.lock()
+	c.cancel()
+	c.mu.Unlock()
+	c.dispatcher.Close()
+}
+
+// DeleteExpired removes the expired entries right away, instead of waiting for the cleanup goroutine.
+func (c *Cache[K, V]) DeleteExpired() {
+	c.lock()
+	defer c.unlock()
+
+	c.expireEntries()
+}
+
+// lock acquires the write lock, reporting the time waited to the observer.
+func (c *Cache[K, V]) lock() {
+	if c.observer == nil {
+		c.mu.Lock()
+		return
+	}
+
+	start := time.Now()
+	c.mu.Lock()
+	c.observer.OnLockWait(time.Since(start))
+}
+
+// rlock acquires the read lock, reporting the time waited to the observer.
+func (c *Cache[K, V]) rlock() {
+	if c.observer == nil {
+		c.mu.RLock()
+		return
+	}
+
+	start := time.Now()
+	c.mu.RLock()
+	c.observer.OnLockWait(time.Since(start))
+}
+
+// unlock releases the write lock, then calls the eviction callback
+// for the entries evicted while it was held.
+func (c *Cache[K, V]) unlock() {
+	if len(c.evictions) == 0 {
+		c.mu.Unlock()
+		return
+	}
+
+	callback, evictions := c.callback, c.evictions
+	c.evictions = nil
+	queued := c.dispatcher.Enqueue(callback, evictions)
+	c.mu.Unlock()
+	if !queued {
+		c.dispatcher.Dispatch(callback, evictions)
+	}
+}
+
+// lockHits acquires the lock needed to record hits with hit:
+// the read lock if the policy is a Visitor, the write lock otherwise.
+func (c *Cache[K, V]) lockHits() {
+	if c.visitor != nil {
+		c.rlock()
+		return
+	}
+	c.lock()
+}
+
+// unlockHits releases the lock acquired by lockHits.
+func (c *Cache[K, V]) unlockHits() {
+	if c.visitor != nil {
+		c.mu.RUnlock()
+		return
+	}
+	c.unlock()
+}
+
+// hit records an access to the key, with the lock acquired by lockHits held.
+func (c *Cache[K, V]) hit(key K) {
+	if c.visitor != nil {
+		c.visitor.Visit(key)
+		return
+	}
+	c.policy.Hit(key)
+}
+
+// evicted removes the entry of a key evicted by the policy.
+func (c *Cache[K, V]) evicted(key K) {
+	e := c.items[key]
+	reason := types.EvictReason(types.EvictReasonEvicted)
+	if c.expired(e) {
+		// the victim is expired but kept for its grace period, so it's reclaimed rather than sacrificed
+		reason = types.EvictReasonExpired
+	}
+	c.deleteEntry(e, reason)
+}
+
+// removeEntry removes the entry from the policy and the cache.
+func (c *Cache[K, V]) removeEntry(e *entry[K, V], reason types.EvictReason) {
+	c.policy.Remove(e.key)
+	c.deleteEntry(e, reason)
+}
+
+// deleteEntry removes the entry from the cache, once the policy is done with it.
+func (c *Cache[K, V]) deleteEntry(e *entry[K, V], reason types.EvictReason) {
+	if c.callback != nil {
+		c.evictions = append(c.evictions, dispatch.Eviction[K, V]{Key: e.key, Value: e.value, Reason: reason})
+	}
+	c.stats.Evict(reason)
+
+	c.weight -= e.weight
+	c.unschedule(e)
+	delete(c.items, e.key)
+}
+
+// capacity returns the maximum total weight of the entries.
+func (c *Cache[K, V]) capacity() int64 {
+	if c.weigher != nil {
+		return c.maxWeight
+	}
+	return int64(c.size)
+}
+
+// weigh returns the weight of the entry. It panics if the weigher returns a negative weight,
+// which would lower the total weight of the cache and let it grow without bound.
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+
+	weight := c.weigher(key, value)
+	if weight < 0 {
+		panic(fmt.Sprintf("%s: weigher returned the negative weight %d for key %v", c.name, weight, key))
+	}
+	return weight
+}
+
+// admit reports whether the admission policy lets the new key take the place of the next entry to be evicted.
+func (c *Cache[K, V]) admit(key K) bool {
+	if c.admission == nil {
+		return true
+	}
+
+	victim, ok := c.policy.Victim(key)
+	return !ok || c.admission.Admit(key, victim)
+}
+
+// schedule schedules the timer of the entry to expire it at its deadline.
+func (c *Cache[K, V]) schedule(e *entry[K, V]) {
+	if e.expiredAt.IsZero() {
+		return
+	}
+	c.startCleanup()
+
+	e.timer.Value = e.key
+	c.wheel.Schedule(&e.timer, e.expiredAt.Add(c.grace))
+}
+
+func (c *Cache[K, V]) unschedule(e *entry[K, V]) {
+	c.wheel.Cancel(&e.timer)
+}
+
+// expireEntries advances the expiration wheel and removes the expired entries.
+func (c *Cache[K, V]) expireEntries() {
+	var start time.Time
+	if c.observer != nil {
+		start = time.Now()
+	}
+
+	expired := c.wheel.Advance(c.clock.Now())
+	for _, key := range expired {
+		c.removeEntry(c.items[key], types.EvictReasonExpired)
+	}
+
+	if c.observer != nil {
+		c.observer.OnExpireSweep(len(expired), time.Since(start))
+	}
+}
+
+// expired reports whether the TTL of the entry has expired.
+func (c *Cache[K, V]) expired(e *entry[K, V]) bool {
+	return !e.expiredAt.IsZero() && !c.clock.Now().Before(e.expiredAt)
+}
+
+// stale reports whether the expired entry is retained for its grace period.
+func (c *Cache[K, V]) stale(e *entry[K, V]) bool {
+	return c.grace > 0 && c.clock.Now().Before(e.expiredAt.Add(c.grace))
+}
+
+// expire removes the expired entry, unless it's retained for its grace period.
+func (c *Cache[K, V]) expire(e *entry[K, V]) {
+	if !c.stale(e) {
+		c.removeEntry(e, types.EvictReasonExpired)
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+// Policy decides which keys are evicted from the cache.
+// Its methods are called with the write lock of the cache held,
+// and only with keys in the cache, except for Add and the incoming keys.
+type Policy[K comparable] interface {
+	// Add adds a new key to the cache, once Evict made room for it, and reports whether
+	// the policy remembered the key as recently evicted, such as in a ghost queue.
+	Add(key K, weight int64) (ghost bool)
+
+	// Hit records an access to the key.
+	Hit(key K)
+
+	// Update sets the weight of the key, as its value has been replaced or the weigher has changed.
+	// It doesn't count as an access, which is recorded with Hit.
+	Update(key K, weight int64)
+
+	// Remove removes the key, as it has been removed or has expired.
+	Remove(key K)
+
+	// Evict evicts at least one key, calling evict for each of them once it's removed from the policy.
+	// incoming points to the new key Evict makes room for, and is nil when the cache shrinks
+	// or an updated entry grows. It's only called when the cache is not empty.
+	Evict(incoming *K, evict func(key K))
+
+	// Victim returns the key the admission policy weighs the incoming key against,
+	// usually the next key Evict would evict, without changing the policy.
+	Victim(incoming K) (key K, ok bool)
+
+	// Resize sets the capacity of the cache, the maximum number of entries or total weight of the entries.
+	// It's called once before the policy is used.
+	Resize(capacity int64)
+
+	// Reset removes all the keys.
+	Reset()
+}
+
+// Visitor is implemented by the policies recording the hits with atomic operations,
+// such as setting a visited bit. Visit is called instead of Hit with only the read lock held,
+// so that concurrent hits don't contend for the write lock.
+type Visitor[K comparable] interface {
+	// Visit records an access to the key, concurrently with the other calls to Visit.
+	Visit(key K)
+}
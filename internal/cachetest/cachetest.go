@@ -0,0 +1,1370 @@
+// Package cachetest holds the behavioral tests shared by the caches built on the internal cache,
+// so that they are held to the same semantics whatever their eviction policy.
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/admission"
+	"github.com/scalalang2/golang-fifo/fakeclock"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Cache is the cache under test.
+type Cache interface {
+	types.StaleLoadingCache[int, int]
+	types.BatchCache[int, int]
+	types.AtomicCache[int, int]
+
+	SetWithTTL(key int, value int, ttl time.Duration)
+	DeleteExpired()
+	All() iter.Seq2[int, int]
+	Keys() iter.Seq[int]
+	Values() iter.Seq[int]
+	Resize(newSize int) (evicted int)
+	SetWeigher(weigher types.Weigher[int, int], maxWeight int64)
+	Weight() int64
+	Stats() types.Stats
+	ResetStats()
+}
+
+// NewCache creates the cache under test, holding up to size entries.
+type NewCache func(size int, opts ...types.Option[int, int]) (Cache, error)
+
+// Run runs the behavioral tests against the caches created by newCache.
+func Run(t *testing.T, newCache NewCache) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newCache NewCache)
+	}{
+		{"GetAndSet", testGetAndSet},
+		{"Remove", testRemove},
+		{"Contains", testContains},
+		{"Len", testLen},
+		{"Purge", testPurge},
+		{"TimeToLive", testTimeToLive},
+		{"BackgroundCleanup", testBackgroundCleanup},
+		{"EvictionCallback", testEvictionCallback},
+		{"EvictionCallbackWithTTL", testEvictionCallbackWithTTL},
+		{"EvictionCallbackUsesCache", testEvictionCallbackUsesCache},
+		{"LargerWorkloadsThanCacheSize", testLargerWorkloadsThanCacheSize},
+		{"Weigher", testWeigher},
+		{"SetWeigher", testSetWeigher},
+		{"NegativeWeight", testNegativeWeight},
+		{"SetWithTTL", testSetWithTTL},
+		{"StrictExpiry", testStrictExpiry},
+		{"SetReclaimsExpiredEntries", testSetReclaimsExpiredEntries},
+		{"SetReclaimsEntriesExpiredWithinTick", testSetReclaimsEntriesExpiredWithinTick},
+		{"GetOrLoad", testGetOrLoad},
+		{"GetOrLoadDeduplicates", testGetOrLoadDeduplicates},
+		{"GetOrLoadContextCanceled", testGetOrLoadContextCanceled},
+		{"ConcurrentGetAndSet", testConcurrentGetAndSet},
+		{"All", testAll},
+		{"Resize", testResize},
+		{"BatchOperations", testBatchOperations},
+		{"GetOrSet", testGetOrSet},
+		{"Compute", testCompute},
+		{"CompareAndSwap", testCompareAndSwap},
+		{"ComputeKeepsTTL", testComputeKeepsTTL},
+		{"EvictionCallbackPanics", testEvictionCallbackPanics},
+		{"EvictionCallbackPanicsReported", testEvictionCallbackPanicsReported},
+		{"Stats", testStats},
+		{"AsyncEviction", testAsyncEviction},
+		{"AsyncEvictionCallbackUsesCache", testAsyncEvictionCallbackUsesCache},
+		{"RefreshAhead", testRefreshAhead},
+		{"RefreshAheadError", testRefreshAheadError},
+		{"StaleWhileError", testStaleWhileError},
+		{"NegativeCaching", testNegativeCaching},
+		{"Observer", testObserver},
+		{"ObserverConcurrentGets", testObserverConcurrentGets},
+		{"Admission", testAdmission},
+		{"AdmissionRecordsOncePerAccess", testAdmissionRecordsOncePerAccess},
+		{"InvalidOptions", testInvalidOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newCache)
+		})
+	}
+}
+
+func mustNew(t *testing.T, newCache NewCache, size int, opts ...types.Option[int, int]) Cache {
+	t.Helper()
+
+	cache, err := newCache(size, opts...)
+	assert.NoError(t, err)
+	return cache
+}
+
+// advance moves the clock forward and sweeps the expired entries,
+// so that they are removed deterministically.
+func advance(cache Cache, clock *fakeclock.Clock, d time.Duration) {
+	clock.Advance(d)
+	cache.DeleteExpired()
+}
+
+// recorder records the evictions of a cache.
+type recorder struct {
+	mu      sync.Mutex
+	reasons map[int]types.EvictReason
+	values  map[int]int
+}
+
+func newRecorder(cache Cache) *recorder {
+	r := &recorder{
+		reasons: make(map[int]types.EvictReason),
+		values:  make(map[int]int),
+	}
+	cache.SetOnEvicted(func(key int, value int, reason types.EvictReason) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.reasons[key] = reason
+		r.values[key] = value
+	})
+	return r
+}
+
+func (r *recorder) snapshot() (map[int]types.EvictReason, map[int]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reasons := make(map[int]types.EvictReason, len(r.reasons))
+	values := make(map[int]int, len(r.values))
+	for k, v := range r.reasons {
+		reasons[k] = v
+	}
+	for k, v := range r.values {
+		values[k] = v
+	}
+	return reasons, values
+}
+
+func testGetAndSet(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	for i := 1; i <= 10; i++ {
+		cache.Set(i, i*10)
+	}
+
+	for i := 1; i <= 10; i++ {
+		value, ok := cache.Get(i)
+		assert.True(t, ok)
+		assert.Equal(t, i*10, value)
+	}
+
+	// the value of an existing key is updated
+	cache.Set(1, 100)
+	value, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 100, value)
+}
+
+func testRemove(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	cache.Set(1, 10)
+	assert.True(t, cache.Remove(1))
+	_, ok := cache.Get(1)
+	assert.False(t, ok)
+
+	// removing a missing key does nothing
+	assert.False(t, cache.Remove(1))
+	assert.False(t, cache.Remove(-1))
+
+	reasons, _ := evictions.snapshot()
+	assert.Equal(t, map[int]types.EvictReason{1: types.EvictReasonRemoved}, reasons)
+}
+
+func testContains(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	assert.False(t, cache.Contains(1))
+	cache.Set(1, 1)
+	assert.True(t, cache.Contains(1))
+
+	value, ok := cache.Peek(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	_, ok = cache.Peek(2)
+	assert.False(t, ok)
+}
+
+func testLen(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	assert.Equal(t, 0, cache.Len())
+	cache.Set(1, 1)
+	assert.Equal(t, 1, cache.Len())
+
+	// duplicated keys only update the value
+	cache.Set(1, 1)
+	assert.Equal(t, 1, cache.Len())
+
+	cache.Set(2, 2)
+	assert.Equal(t, 2, cache.Len())
+
+	// the cache never holds more than its size
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+	assert.Equal(t, 10, cache.Len())
+}
+
+func testPurge(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Purge()
+	assert.Equal(t, 0, cache.Len())
+	assert.False(t, cache.Contains(1))
+
+	reasons, _ := evictions.snapshot()
+	assert.Equal(t, map[int]types.EvictReason{1: types.EvictReasonRemoved, 2: types.EvictReasonRemoved}, reasons)
+
+	// the cache is usable after a purge
+	for i := 0; i < 20; i++ {
+		cache.Set(i, i)
+	}
+	assert.Equal(t, 10, cache.Len())
+}
+
+func testTimeToLive(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	defer cache.Close()
+
+	for i := 1; i <= 10; i++ {
+		cache.Set(i, i)
+		value, ok := cache.Get(i)
+		assert.True(t, ok)
+		assert.Equal(t, i, value)
+	}
+
+	advance(cache, clock, 2*time.Second)
+
+	for i := 1; i <= 10; i++ {
+		_, ok := cache.Get(i)
+		assert.False(t, ok)
+	}
+	assert.Equal(t, 0, cache.Len())
+}
+
+func testBackgroundCleanup(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	defer cache.Close()
+
+	expired := make(chan int, 1)
+	cache.SetOnEvicted(func(key int, _ int, reason types.EvictReason) {
+		assert.Equal(t, types.EvictReason(types.EvictReasonExpired), reason)
+		expired <- key
+	})
+
+	// the clock is advanced right after the first entry starts the cleanup,
+	// which must sweep it without any other call to the cache
+	cache.Set(1, 1)
+	clock.Advance(2 * time.Second)
+
+	select {
+	case key := <-expired:
+		assert.Equal(t, 1, key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the expired entry was not swept")
+	}
+}
+
+func testEvictionCallback(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	for i := 1; i <= 11; i++ {
+		cache.Set(i, i*10)
+	}
+
+	// a single entry is evicted to make room for the last one, whichever the policy picks
+	reasons, values := evictions.snapshot()
+	assert.Equal(t, 1, len(reasons))
+	for key, reason := range reasons {
+		assert.Equal(t, types.EvictReason(types.EvictReasonEvicted), reason)
+		assert.Equal(t, key*10, values[key])
+		assert.False(t, cache.Contains(key))
+	}
+	assert.Equal(t, 10, cache.Len())
+}
+
+func testEvictionCallbackWithTTL(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	for i := 1; i <= 10; i++ {
+		cache.Set(i, i)
+	}
+
+	advance(cache, clock, 2*time.Second)
+
+	reasons, values := evictions.snapshot()
+	assert.Equal(t, 10, len(reasons))
+	for i := 1; i <= 10; i++ {
+		assert.Equal(t, types.EvictReason(types.EvictReasonExpired), reasons[i])
+		assert.Equal(t, i, values[i])
+	}
+}
+
+func testEvictionCallbackUsesCache(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 2)
+	defer cache.Close()
+
+	var lens []int
+	cache.SetOnEvicted(func(key int, _ int, _ types.EvictReason) {
+		// the callback is called once the lock is released
+		lens = append(lens, cache.Len())
+		if len(lens) == 1 {
+			// the evictions caused by the callback are dispatched before Set returns
+			cache.Set(10, 10)
+			assert.Equal(t, 2, len(lens))
+		}
+	})
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+	assert.Equal(t, []int{2, 2}, lens)
+	assert.Equal(t, 2, cache.Len())
+	assert.True(t, cache.Contains(10))
+}
+
+func testLargerWorkloadsThanCacheSize(t *testing.T, newCache NewCache) {
+	// the entries expire while the workload runs, as the clock moves forward
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 512, types.WithTTL[int, int](time.Millisecond), types.WithClock[int, int](clock))
+	defer cache.Close()
+
+	for i := 0; i < 10240; i++ {
+		cache.Set(i, i)
+		clock.Advance(time.Microsecond)
+
+		value, ok := cache.Peek(i)
+		assert.True(t, ok)
+		assert.Equal(t, i, value)
+		assert.True(t, cache.Len() <= 512)
+	}
+}
+
+func testWeigher(t *testing.T, newCache NewCache) {
+	// the value of an entry is its weight
+	cache := mustNew(t, newCache, 100, types.WithWeigher(func(_ int, value int) int64 {
+		return int64(value)
+	}, 100))
+	defer cache.Close()
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 10000; i++ {
+		key := rng.IntN(200)
+		switch rng.IntN(10) {
+		case 0:
+			cache.Remove(key)
+		case 1, 2:
+			cache.Get(key)
+		default:
+			// the updated entries grow or shrink
+			cache.Set(key, 1+rng.IntN(20))
+		}
+
+		assert.True(t, cache.Weight() <= 100)
+		assert.True(t, int64(cache.Len()) <= cache.Weight())
+	}
+
+	// an entry heavier than the cache is not stored
+	cache.Set(1000, 101)
+	assert.False(t, cache.Contains(1000))
+
+	cache.Resize(50)
+	assert.True(t, cache.Weight() <= 50)
+	for i := 0; i < 1000; i++ {
+		cache.Set(rng.IntN(200), 1+rng.IntN(20))
+		assert.True(t, cache.Weight() <= 50)
+	}
+
+	cache.Purge()
+	assert.Equal(t, int64(0), cache.Weight())
+	assert.Equal(t, 0, cache.Len())
+}
+
+func testNegativeWeight(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10, types.WithWeigher(func(_ int, value int) int64 {
+		return int64(value)
+	}, 10))
+	defer cache.Close()
+
+	func() {
+		defer func() {
+			r := recover()
+			assert.True(t, r != nil, "a negative weight must panic")
+			assert.Contains(t, fmt.Sprint(r), "negative weight -1 for key 1")
+		}()
+		cache.Set(1, -1)
+	}()
+
+	// the entry is not stored, and the cache is still usable
+	assert.False(t, cache.Contains(1))
+	cache.Set(2, 2)
+	assert.Equal(t, int64(2), cache.Weight())
+}
+
+func testSetWithTTL(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	defer cache.Close()
+
+	cache.SetWithTTL(1, 1, 0)         // never expires
+	cache.Set(2, 2)                   // expires after the default TTL
+	cache.SetWithTTL(3, 3, time.Hour) // outlives the default TTL
+
+	advance(cache, clock, 2*time.Second)
+
+	assert.True(t, cache.Contains(1))
+	assert.False(t, cache.Contains(2))
+	assert.True(t, cache.Contains(3))
+}
+
+func testStrictExpiry(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	for i := 1; i <= 4; i++ {
+		cache.Set(i, i)
+	}
+
+	// the clock moves past the TTL, but the expired entries are not swept yet
+	clock.Advance(time.Second)
+
+	_, ok := cache.Get(1)
+	assert.False(t, ok)
+	_, ok = cache.Peek(2)
+	assert.False(t, ok)
+	assert.False(t, cache.Contains(3))
+	assert.False(t, cache.Remove(4))
+
+	// the expired entries are removed lazily
+	assert.Equal(t, 0, cache.Len())
+	reasons, _ := evictions.snapshot()
+	assert.Equal(t, 4, len(reasons))
+	for _, reason := range reasons {
+		assert.Equal(t, types.EvictReason(types.EvictReasonExpired), reason)
+	}
+}
+
+func testSetReclaimsExpiredEntries(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithClock[int, int](clock))
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	for i := 1; i <= 9; i++ {
+		cache.Set(i, i)
+	}
+	cache.SetWithTTL(10, 10, time.Second)
+	clock.Advance(2 * time.Second)
+
+	// the expired entry is reclaimed instead of evicting a live one
+	cache.Set(11, 11)
+	reasons, _ := evictions.snapshot()
+	assert.Equal(t, map[int]types.EvictReason{10: types.EvictReasonExpired}, reasons)
+	for i := 1; i <= 9; i++ {
+		assert.True(t, cache.Contains(i))
+	}
+	assert.True(t, cache.Contains(11))
+}
+
+func testSetReclaimsEntriesExpiredWithinTick(t *testing.T, newCache NewCache) {
+	// without a default TTL, the expiration wheel ticks every second,
+	// so the clock starts at the beginning of a tick for the expiration to fall within it
+	clock := fakeclock.New(time.Now().Truncate(time.Second))
+	cache := mustNew(t, newCache, 3, types.WithClock[int, int](clock))
+	defer cache.Close()
+	evictions := newRecorder(cache)
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.SetWithTTL(3, 3, 100*time.Millisecond)
+	clock.Advance(500 * time.Millisecond)
+
+	// the entry expired before the next tick is still reclaimed instead of evicting a live one
+	cache.Set(4, 4)
+	reasons, _ := evictions.snapshot()
+	assert.Equal(t, map[int]types.EvictReason{3: types.EvictReasonExpired}, reasons)
+	assert.True(t, cache.Contains(1))
+	assert.True(t, cache.Contains(2))
+	assert.True(t, cache.Contains(4))
+}
+
+func testGetOrLoad(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	value, err := cache.GetOrLoad(context.Background(), 1, func(_ context.Context, key int) (int, error) {
+		return key * 10, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, value)
+
+	// the loaded value is set on cache
+	value, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, value)
+
+	errLoad := errors.New("load failed")
+	_, err = cache.GetOrLoad(context.Background(), 2, func(_ context.Context, _ int) (int, error) {
+		return 0, errLoad
+	})
+	assert.True(t, errors.Is(err, errLoad))
+	assert.False(t, cache.Contains(2))
+}
+
+func testGetOrLoadDeduplicates(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	errLoad := errors.New("load failed")
+	loader := func(_ context.Context, _ int) (int, error) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return 0, errLoad
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetOrLoad(context.Background(), 1, loader)
+			assert.True(t, errors.Is(err, errLoad))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.False(t, cache.Contains(1))
+}
+
+func testGetOrLoadContextCanceled(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := cache.GetOrLoad(ctx, 1, func(ctx context.Context, _ int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func testConcurrentGetAndSet(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 100)
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if v, ok := cache.Get(i % 200); ok {
+					assert.Equal(t, i%200, v)
+				}
+				cache.Peek(i % 200)
+				cache.Contains(i % 200)
+			}
+		}()
+	}
+
+	// writers evict entries while readers record their accesses
+	for i := 100; i < 1000; i++ {
+		cache.Set(i%200, i%200)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, cache.Len())
+}
+
+func testEvictionCallbackPanics(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 1)
+	defer cache.Close()
+
+	cache.SetOnEvicted(func(_ int, _ int, _ types.EvictReason) {
+		panic("boom")
+	})
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Remove(2)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func testEvictionCallbackPanicsReported(t *testing.T, newCache NewCache) {
+	var mu sync.Mutex
+	recovered := make(map[int]string)
+	cache := mustNew(t, newCache, 1, types.WithOnCallbackPanic(func(key int, _ int, reason types.EvictReason, r any) {
+		mu.Lock()
+		defer mu.Unlock()
+		recovered[key] = fmt.Sprintf("%v: %v", reason, r)
+	}))
+	defer cache.Close()
+
+	cache.SetOnEvicted(func(_ int, _ int, _ types.EvictReason) {
+		panic("boom")
+	})
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[int]string{1: "evicted: boom"}, recovered)
+}
+
+func testStats(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(1, 10)
+	cache.Get(1)
+	cache.Get(2)
+	cache.Set(2, 2)
+	cache.Remove(2)
+
+	// peeking doesn't count as a get
+	cache.Peek(1)
+	cache.Peek(2)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(2), stats.Sets)
+	assert.Equal(t, uint64(1), stats.Updates)
+	assert.Equal(t, uint64(1), stats.Evictions[types.EvictReasonRemoved])
+
+	cache.ResetStats()
+	assert.Equal(t, types.Stats{}, cache.Stats())
+}
+
+func testAsyncEviction(t *testing.T, newCache NewCache) {
+	var mu sync.Mutex
+	evicted := make(map[int]int)
+	cache := mustNew(t, newCache, 10,
+		types.WithAsyncEviction[int, int](4, 16),
+		types.WithOnEvicted(func(key int, _ int, _ types.EvictReason) {
+			mu.Lock()
+			evicted[key]++
+			mu.Unlock()
+		}),
+	)
+
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+	cache.Close()
+
+	// every entry is evicted once, and delivered once the cache is closed
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 100, len(evicted))
+	for _, count := range evicted {
+		assert.Equal(t, 1, count)
+	}
+}
+
+func testAsyncEvictionCallbackUsesCache(t *testing.T, newCache NewCache) {
+	var mu sync.Mutex
+	evicted := make(map[int]int)
+	var cache Cache
+	cache = mustNew(t, newCache, 10,
+		types.WithAsyncEviction[int, int](1, 0),
+		types.WithOnEvicted(func(key int, value int, _ types.EvictReason) {
+			mu.Lock()
+			evicted[key]++
+			mu.Unlock()
+			// the worker evicts entries in turn, without waiting for itself
+			if key < 1000 {
+				cache.Set(key+1000, value)
+			}
+		}),
+	)
+
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+	cache.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 1, evicted[i])
+	}
+}
+
+func testInvalidOptions(t *testing.T, newCache NewCache) {
+	_, err := newCache(0)
+	assert.True(t, errors.Is(err, types.ErrInvalidSize))
+
+	_, err = newCache(10, types.WithWeigher[int, int](func(_ int, _ int) int64 {
+		return 1
+	}, 0))
+	assert.True(t, errors.Is(err, types.ErrInvalidMaxWeight))
+
+	_, err = newCache(10, types.WithCleanupInterval[int, int](-1))
+	assert.True(t, errors.Is(err, types.ErrInvalidCleanupInterval))
+
+	_, err = newCache(10, types.WithAsyncEviction[int, int](-1, 0))
+	assert.True(t, errors.Is(err, types.ErrInvalidAsyncEviction))
+
+	_, err = newCache(10, types.WithRefreshAhead[int, int](func(_ context.Context, key int) (int, error) {
+		return key, nil
+	}, 0))
+	assert.True(t, errors.Is(err, types.ErrInvalidRefreshAhead))
+
+	_, err = newCache(10, types.WithNegativeCaching[int, int](1, 0, 0))
+	assert.True(t, errors.Is(err, types.ErrInvalidNegativeCaching))
+}
+
+func testSetWeigher(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	// the value of an entry is its weight
+	cache.SetWeigher(func(_ int, value int) int64 {
+		return int64(value)
+	}, 100)
+
+	for i := 0; i < 10; i++ {
+		cache.Set(i, 10)
+	}
+	assert.Equal(t, int64(100), cache.Weight())
+
+	// the cache evicts entries until the new one fits
+	cache.Set(10, 20)
+	assert.Equal(t, 9, cache.Len())
+	assert.Equal(t, int64(100), cache.Weight())
+	assert.True(t, cache.Contains(10))
+
+	// an entry heavier than the max weight is rejected
+	cache.Set(11, 101)
+	assert.False(t, cache.Contains(11))
+	assert.Equal(t, int64(100), cache.Weight())
+
+	// updating an entry updates the total weight
+	cache.Set(10, 1)
+	assert.Equal(t, int64(81), cache.Weight())
+}
+
+func testAll(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithClock[int, int](clock))
+	defer cache.Close()
+
+	for i := 1; i <= 5; i++ {
+		cache.Set(i, i*10)
+	}
+	cache.SetWithTTL(6, 60, time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+
+	// expired entries are skipped
+	entries := make(map[int]int)
+	for k, v := range cache.All() {
+		entries[k] = v
+	}
+	assert.Equal(t, map[int]int{1: 10, 2: 20, 3: 30, 4: 40, 5: 50}, entries)
+
+	keys := 0
+	for k := range cache.Keys() {
+		// the cache can be modified while iterating
+		cache.Remove(k)
+		keys++
+	}
+	assert.Equal(t, 5, keys)
+
+	for range cache.Values() {
+		t.Fatal("cache should be empty")
+	}
+}
+
+func testResize(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+	r := newRecorder(cache)
+
+	for i := 1; i <= 10; i++ {
+		cache.Set(i, i)
+	}
+
+	// shrinking evicts entries through the eviction policy
+	assert.Equal(t, 5, cache.Resize(5))
+	assert.Equal(t, 5, cache.Len())
+	reasons, _ := r.snapshot()
+	assert.Equal(t, 5, len(reasons))
+	for _, reason := range reasons {
+		assert.Equal(t, types.EvictReason(types.EvictReasonEvicted), reason)
+	}
+
+	// growing makes room for more entries
+	assert.Equal(t, 0, cache.Resize(20))
+	for i := 11; i <= 25; i++ {
+		cache.Set(i, i)
+	}
+	assert.Equal(t, 20, cache.Len())
+}
+
+func testBatchOperations(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 5)
+	defer cache.Close()
+
+	var evicted []int
+	cache.SetOnEvicted(func(key int, _ int, _ types.EvictReason) {
+		evicted = append(evicted, key)
+	})
+
+	entries := make([]types.Entry[int, int], 0, 7)
+	for i := 1; i <= 7; i++ {
+		entries = append(entries, types.Entry[int, int]{Key: i, Value: i * 10})
+	}
+	cache.SetMany(entries)
+
+	// the eviction callbacks are called once the whole batch is set
+	assert.Equal(t, 2, len(evicted))
+	assert.Equal(t, 5, cache.Len())
+	for _, key := range evicted {
+		assert.False(t, cache.Contains(key))
+	}
+
+	found := cache.GetMany([]int{evicted[0], 7})
+	assert.Equal(t, map[int]int{7: 70}, found)
+
+	removed := cache.RemoveMany([]int{7, 7, 8, evicted[0]})
+	assert.Equal(t, []bool{true, false, false, false}, removed)
+	assert.Equal(t, 4, cache.Len())
+}
+
+func testGetOrSet(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	actual, loaded := cache.GetOrSet(1, 10)
+	assert.False(t, loaded)
+	assert.Equal(t, 10, actual)
+
+	actual, loaded = cache.GetOrSet(1, 20)
+	assert.True(t, loaded)
+	assert.Equal(t, 10, actual)
+}
+
+func testCompute(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	increment := func(old int, ok bool) (int, types.ComputeOp) {
+		return old + 1, types.ComputeUpdate
+	}
+
+	value, ok := cache.Compute(1, increment)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	value, ok = cache.Compute(1, increment)
+	assert.True(t, ok)
+	assert.Equal(t, 2, value)
+
+	value, ok = cache.Compute(1, func(old int, ok bool) (int, types.ComputeOp) {
+		return 0, types.ComputeKeep
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 2, value)
+
+	_, ok = cache.Compute(1, func(old int, ok bool) (int, types.ComputeOp) {
+		return 0, types.ComputeDelete
+	})
+	assert.False(t, ok)
+	assert.False(t, cache.Contains(1))
+
+	// keeping an absent key leaves the cache as it is
+	_, ok = cache.Compute(2, func(old int, ok bool) (int, types.ComputeOp) {
+		assert.False(t, ok)
+		return 0, types.ComputeKeep
+	})
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+
+	// concurrent increments are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Compute(3, increment)
+		}()
+	}
+	wg.Wait()
+	value, _ = cache.Get(3)
+	assert.Equal(t, 100, value)
+}
+
+func testCompareAndSwap(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10)
+	defer cache.Close()
+
+	assert.False(t, cache.CompareAndSwap(1, 0, 10))
+
+	cache.Set(1, 10)
+	assert.False(t, cache.CompareAndSwap(1, 0, 20))
+	assert.True(t, cache.CompareAndSwap(1, 10, 20))
+
+	value, _ := cache.Get(1)
+	assert.Equal(t, 20, value)
+}
+
+func testComputeKeepsTTL(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	defer cache.Close()
+
+	increment := func(old int, ok bool) (int, types.ComputeOp) {
+		return old + 1, types.ComputeUpdate
+	}
+
+	// the updated entries keep their own TTL, the new ones get the default TTL
+	cache.SetWithTTL(1, 1, 0)
+	cache.SetWithTTL(2, 2, 10*time.Second)
+	cache.Compute(1, increment)
+	assert.True(t, cache.CompareAndSwap(2, 2, 20))
+	cache.Compute(3, increment)
+
+	advance(cache, clock, 2*time.Second)
+	assert.True(t, cache.Contains(1))
+	assert.True(t, cache.Contains(2))
+	assert.False(t, cache.Contains(3))
+
+	advance(cache, clock, 10*time.Second)
+	assert.True(t, cache.Contains(1))
+	assert.False(t, cache.Contains(2))
+}
+
+func testRefreshAhead(t *testing.T, newCache NewCache) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10,
+		types.WithTTL[int, int](10*time.Second),
+		types.WithClock[int, int](clock),
+		types.WithRefreshAhead(func(_ context.Context, key int) (int, error) {
+			loads.Add(1)
+			<-release
+			return key * 100, nil
+		}, 0.2),
+	)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+
+	// the entry is not refreshed before the last 20% of its TTL
+	clock.Advance(7 * time.Second)
+	value, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, int32(0), loads.Load())
+
+	// the current value is served while the entry is refreshed once
+	clock.Advance(time.Second)
+	for i := 0; i < 10; i++ {
+		value, ok = cache.Get(1)
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for value, _ := cache.Peek(1); value != 100 && time.Now().Before(deadline); value, _ = cache.Peek(1) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int32(1), loads.Load())
+
+	// the refresh is neither an update nor a hit
+	stats := cache.Stats()
+	assert.Equal(t, uint64(0), stats.Updates)
+	assert.Equal(t, uint64(11), stats.Hits)
+
+	// the refreshed entry lives for its whole TTL again
+	advance(cache, clock, 9*time.Second)
+	value, ok = cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 100, value)
+}
+
+func testRefreshAheadError(t *testing.T, newCache NewCache) {
+	var loads atomic.Int32
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10,
+		types.WithTTL[int, int](10*time.Second),
+		types.WithClock[int, int](clock),
+		types.WithRefreshAhead(func(_ context.Context, _ int) (int, error) {
+			loads.Add(1)
+			return 0, errors.New("unavailable")
+		}, 0.5),
+	)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	clock.Advance(6 * time.Second)
+
+	// a failed refresh keeps the current value, and a later read retries it
+	deadline := time.Now().Add(time.Second)
+	for loads.Load() < 2 && time.Now().Before(deadline) {
+		value, ok := cache.Get(1)
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, loads.Load() >= 2)
+
+	// the entry still expires at its deadline
+	advance(cache, clock, 4*time.Second)
+	_, ok := cache.Get(1)
+	assert.False(t, ok)
+}
+
+func testStaleWhileError(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10,
+		types.WithTTL[int, int](10*time.Second),
+		types.WithClock[int, int](clock),
+		types.WithStaleWhileError[int, int](5*time.Second),
+	)
+	defer cache.Close()
+	r := newRecorder(cache)
+
+	errUnavailable := errors.New("unavailable")
+	failing := func(_ context.Context, _ int) (int, error) {
+		return 0, errUnavailable
+	}
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+	advance(cache, clock, 11*time.Second)
+
+	// the expired entries are retained, but only served by a failing loading get
+	_, ok := cache.Get(1)
+	assert.False(t, ok)
+	assert.False(t, cache.Contains(1))
+	reasons, _ := r.snapshot()
+	assert.Equal(t, 0, len(reasons))
+
+	value, stale, err := cache.GetOrLoadStale(context.Background(), 1, failing)
+	assert.NoError(t, err)
+	assert.True(t, stale)
+	assert.Equal(t, 1, value)
+
+	_, err = cache.GetOrLoad(context.Background(), 1, failing)
+	assert.True(t, errors.Is(err, errUnavailable))
+
+	// a reload given up by the caller still serves the expired value, with the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	value, stale, err = cache.GetOrLoadStale(ctx, 3, func(ctx context.Context, _ int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, stale)
+	assert.Equal(t, 3, value)
+
+	value, stale, err = cache.GetOrLoadStale(context.Background(), 1, func(_ context.Context, key int) (int, error) {
+		return key * 10, nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, 10, value)
+
+	// removing a retained entry drops it as expired
+	assert.False(t, cache.Remove(2))
+	reasons, _ = r.snapshot()
+	assert.Equal(t, types.EvictReason(types.EvictReasonExpired), reasons[2])
+
+	// the entries are removed once their grace period is over
+	advance(cache, clock, 5*time.Second)
+	reasons, _ = r.snapshot()
+	assert.Equal(t, types.EvictReason(types.EvictReasonExpired), reasons[3])
+
+	_, stale, err = cache.GetOrLoadStale(context.Background(), 3, failing)
+	assert.True(t, errors.Is(err, errUnavailable))
+	assert.False(t, stale)
+	value, ok = cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, value)
+}
+
+func testNegativeCaching(t *testing.T, newCache NewCache) {
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10,
+		types.WithClock[int, int](clock),
+		types.WithNegativeCaching[int, int](2, 10*time.Second, time.Second),
+	)
+	defer cache.Close()
+
+	var loads atomic.Int32
+	errUnavailable := errors.New("unavailable")
+	loader := func(_ context.Context, key int) (int, error) {
+		loads.Add(1)
+		switch key {
+		case 1:
+			return 0, fmt.Errorf("key %d: %w", key, types.ErrNotFound)
+		case 2:
+			return 0, errUnavailable
+		}
+		return key, nil
+	}
+
+	// the keys not found are cached for their own TTL without taking the place of a value
+	for i := 0; i < 3; i++ {
+		_, err := cache.GetOrLoad(context.Background(), 1, loader)
+		assert.True(t, errors.Is(err, types.ErrNotFound))
+	}
+	assert.Equal(t, int32(1), loads.Load())
+	assert.Equal(t, 0, cache.Len())
+	_, ok := cache.Get(1)
+	assert.False(t, ok)
+
+	// the other errors are cached for a shorter TTL
+	for i := 0; i < 3; i++ {
+		_, err := cache.GetOrLoad(context.Background(), 2, loader)
+		assert.True(t, errors.Is(err, errUnavailable))
+	}
+	assert.Equal(t, int32(2), loads.Load())
+
+	clock.Advance(time.Second)
+	_, err := cache.GetOrLoad(context.Background(), 2, loader)
+	assert.True(t, errors.Is(err, errUnavailable))
+	_, err = cache.GetOrLoad(context.Background(), 1, loader)
+	assert.True(t, errors.Is(err, types.ErrNotFound))
+	assert.Equal(t, int32(3), loads.Load())
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(3), stats.NegativeSets)
+	assert.Equal(t, uint64(5), stats.NegativeHits)
+
+	// setting a value replaces the failed load of the key
+	cache.Set(1, 10)
+	value, err := cache.GetOrLoad(context.Background(), 1, loader)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, value)
+
+	// removing a key forgets its failed load
+	cache.Remove(2)
+	value, err = cache.GetOrLoad(context.Background(), 2, func(_ context.Context, key int) (int, error) {
+		return key, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+// recordingObserver records the events of a cache.
+// Its mutex makes it safe for concurrent use, as every observer must be.
+type recordingObserver struct {
+	types.NoopObserver
+
+	mu        sync.Mutex
+	gets      map[bool]int
+	sets      map[bool]int
+	evictions map[types.EvictReason]int
+	swept     int
+	lockWaits int
+}
+
+func newRecordingObserver() *recordingObserver {
+	return &recordingObserver{
+		gets:      make(map[bool]int),
+		sets:      make(map[bool]int),
+		evictions: make(map[types.EvictReason]int),
+	}
+}
+
+func (o *recordingObserver) OnGet(hit bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gets[hit]++
+}
+
+func (o *recordingObserver) OnSet(update bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sets[update]++
+}
+
+func (o *recordingObserver) OnEvict(reason types.EvictReason) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.evictions[reason]++
+}
+
+func (o *recordingObserver) OnExpireSweep(count int, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.swept += count
+}
+
+func (o *recordingObserver) OnLockWait(_ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lockWaits++
+}
+
+func testObserver(t *testing.T, newCache NewCache) {
+	observer := newRecordingObserver()
+	clock := fakeclock.New(time.Now())
+	cache := mustNew(t, newCache, 10,
+		types.WithTTL[int, int](time.Second),
+		types.WithClock[int, int](clock),
+		types.WithObserver[int, int](observer),
+	)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(1, 10)
+	cache.Set(2, 2)
+	cache.Get(1)
+	cache.Get(3)
+	cache.Remove(2)
+	advance(cache, clock, 2*time.Second)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, 2, observer.sets[false])
+	assert.Equal(t, 1, observer.sets[true])
+	assert.Equal(t, 1, observer.gets[true])
+	assert.Equal(t, 1, observer.gets[false])
+	assert.Equal(t, 1, observer.evictions[types.EvictReasonRemoved])
+	assert.Equal(t, 1, observer.evictions[types.EvictReasonExpired])
+	assert.Equal(t, 1, observer.swept)
+	assert.True(t, observer.lockWaits > 0)
+}
+
+func testObserverConcurrentGets(t *testing.T, newCache NewCache) {
+	observer := newRecordingObserver()
+	cache := mustNew(t, newCache, 100, types.WithObserver[int, int](observer))
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+
+	// the observer is called concurrently by the gets
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				cache.Get(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, 800, observer.gets[true])
+	assert.Equal(t, 800, observer.gets[false])
+}
+
+func testAdmission(t *testing.T, newCache NewCache) {
+	cache := mustNew(t, newCache, 10, types.WithAdmission[int, int](admission.NewTinyLFU[int](1000)))
+	defer cache.Close()
+
+	for i := 1; i <= 10; i++ {
+		cache.Set(i, i)
+		for j := 0; j < 5; j++ {
+			cache.Get(i)
+		}
+	}
+
+	// a scan of keys accessed once doesn't flush the hot entries
+	for i := 100; i < 150; i++ {
+		cache.Set(i, i)
+	}
+	for i := 1; i <= 10; i++ {
+		assert.True(t, cache.Contains(i))
+	}
+	assert.Equal(t, uint64(50), cache.Stats().Rejections)
+
+	// an entry is still updated when its key is rejected
+	cache.Set(1, 10)
+	value, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, value)
+}
+
+// countingAdmission counts the accesses recorded for each key, and admits every key.
+type countingAdmission struct {
+	mu      sync.Mutex
+	records map[int]int
+}
+
+func (a *countingAdmission) Record(key int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records[key]++
+}
+
+func (a *countingAdmission) Admit(_, _ int) bool {
+	return true
+}
+
+func testAdmissionRecordsOncePerAccess(t *testing.T, newCache NewCache) {
+	counter := &countingAdmission{records: make(map[int]int)}
+	cache := mustNew(t, newCache, 10, types.WithAdmission[int, int](counter))
+	defer cache.Close()
+
+	loader := func(_ context.Context, key int) (int, error) {
+		return key, nil
+	}
+
+	// the miss of a loading get records the access, setting the loaded value doesn't
+	_, err := cache.GetOrLoad(context.Background(), 1, loader)
+	assert.NoError(t, err)
+	_, _, err = cache.GetOrLoadStale(context.Background(), 2, loader)
+	assert.NoError(t, err)
+	cache.Set(3, 3)
+	cache.Get(3)
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 2}, counter.records)
+}
@@ -0,0 +1,180 @@
+// Package dispatch calls the eviction callbacks of the caches once their lock is released,
+// so that a callback can use the cache and a slow callback doesn't block it.
+package dispatch
+
+import (
+	"hash/maphash"
+	"sync"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Eviction is an entry evicted from the cache, waiting for its callback.
+type Eviction[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Reason types.EvictReason
+}
+
+// item is an eviction bound to the callback set when it happened.
+type item[K comparable, V any] struct {
+	callback types.OnEvictCallback[K, V]
+	eviction Eviction[K, V]
+}
+
+// Dispatcher calls the eviction callbacks once the cache lock is released.
+//
+// In synchronous mode, the goroutine that evicted the entries calls their callbacks itself,
+// after releasing the lock: it never runs the callbacks of the other goroutines, and the cache
+// method returns once they're done. A callback using the cache runs the callbacks of its own
+// evictions before the cache method it called returns.
+//
+// In asynchronous mode, the evictions are queued while the cache lock is held, and a dispatcher
+// goroutine hands them to workers through bounded channels, so that the goroutines evicting entries,
+// workers included, never wait for a callback. The evictions of the same key are always handled
+// by the same worker to keep their order.
+type Dispatcher[K comparable, V any] struct {
+	mu      sync.Mutex
+	queue   []item[K, V]
+	closed  bool
+	stopped bool // stopped is set when the dispatcher goroutine is done with the workers
+
+	seed    maphash.Seed
+	wake    chan struct{}
+	workers []chan item[K, V]
+	wg      sync.WaitGroup
+
+	// onPanic is called with the panics recovered from the callbacks; nil if they're ignored
+	onPanic types.PanicHandler[K, V]
+}
+
+// New creates a dispatcher calling the callbacks synchronously if workers is zero,
+// or with the given number of workers, each buffering up to buffer evictions.
+// The panics of the callbacks are reported to onPanic, if not nil.
+func New[K comparable, V any](workers, buffer int, onPanic types.PanicHandler[K, V]) *Dispatcher[K, V] {
+	d := &Dispatcher[K, V]{
+		seed:    maphash.MakeSeed(),
+		workers: make([]chan item[K, V], workers),
+		onPanic: onPanic,
+		stopped: workers == 0,
+	}
+	if workers == 0 {
+		return d
+	}
+
+	d.wake = make(chan struct{}, 1)
+	for i := range d.workers {
+		d.workers[i] = make(chan item[K, V], buffer)
+		d.wg.Add(1)
+		go d.work(d.workers[i])
+	}
+	d.wg.Add(1)
+	go d.dispatch()
+
+	return d
+}
+
+// Enqueue queues the evictions for the workers and wakes up the dispatcher goroutine.
+// It's called with the cache lock held, so that the evictions of a key are queued in order.
+//
+// It returns false in synchronous mode, or once the dispatcher goroutine is stopped:
+// the caller then calls the callbacks itself with Dispatch, after releasing the lock.
+func (d *Dispatcher[K, V]) Enqueue(callback types.OnEvictCallback[K, V], evictions []Eviction[K, V]) bool {
+	if callback == nil || len(evictions) == 0 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return false
+	}
+	for _, e := range evictions {
+		d.queue = append(d.queue, item[K, V]{callback: callback, eviction: e})
+	}
+	if !d.closed {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Dispatch calls the callback for the evictions Enqueue didn't queue, in order.
+// It's called by the goroutine that evicted the entries, after the cache lock is released.
+func (d *Dispatcher[K, V]) Dispatch(callback types.OnEvictCallback[K, V], evictions []Eviction[K, V]) {
+	for _, e := range evictions {
+		d.call(item[K, V]{callback: callback, eviction: e})
+	}
+}
+
+// Close waits for the workers to handle the queued evictions and stops them.
+// The evictions enqueued afterward are left to their caller.
+func (d *Dispatcher[K, V]) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	if !d.stopped {
+		close(d.wake)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// dispatch hands the queued evictions to the workers each time Enqueue wakes it up.
+// When the dispatcher is closed, it hands them the rest of the queue and stops them.
+func (d *Dispatcher[K, V]) dispatch() {
+	defer d.wg.Done()
+
+	for range d.wake {
+		d.send()
+	}
+
+	// the workers may still enqueue evictions from their callbacks: stop once the queue is empty
+	for d.send() {
+	}
+	for _, w := range d.workers {
+		close(w)
+	}
+}
+
+// send hands the queued evictions to the workers. Once the dispatcher is closed,
+// it marks the dispatcher stopped if the queue is empty, and returns false.
+func (d *Dispatcher[K, V]) send() bool {
+	d.mu.Lock()
+	queue := d.queue
+	d.queue = nil
+	if len(queue) == 0 && d.closed {
+		d.stopped = true
+	}
+	d.mu.Unlock()
+
+	for _, it := range queue {
+		d.workers[maphash.Comparable(d.seed, it.eviction.Key)%uint64(len(d.workers))] <- it
+	}
+	return len(queue) > 0
+}
+
+func (d *Dispatcher[K, V]) work(items <-chan item[K, V]) {
+	defer d.wg.Done()
+	for it := range items {
+		d.call(it)
+	}
+}
+
+// call calls the callback, recovering from a panic so that it doesn't break the cache or the worker,
+// and reports the panic to the panic handler.
+func (d *Dispatcher[K, V]) call(it item[K, V]) {
+	defer func() {
+		if r := recover(); r != nil && d.onPanic != nil {
+			d.onPanic(it.eviction.Key, it.eviction.Value, it.eviction.Reason, r)
+		}
+	}()
+	it.callback(it.eviction.Key, it.eviction.Value, it.eviction.Reason)
+}
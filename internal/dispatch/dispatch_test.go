@@ -0,0 +1,126 @@
+package dispatch
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+func evictions(keys ...int) []Eviction[int, int] {
+	evictions := make([]Eviction[int, int], len(keys))
+	for i, key := range keys {
+		evictions[i] = Eviction[int, int]{Key: key, Value: key, Reason: types.EvictReasonEvicted}
+	}
+	return evictions
+}
+
+func TestSynchronous(t *testing.T) {
+	d := New[int, int](0, 0, nil)
+	var keys []int
+	callback := func(key int, _ int, _ types.EvictReason) {
+		keys = append(keys, key)
+	}
+
+	// the caller dispatches its own evictions
+	assert.False(t, d.Enqueue(callback, evictions(1, 2)))
+	assert.Equal(t, 0, len(keys))
+
+	d.Dispatch(callback, evictions(1, 2))
+	assert.Equal(t, []int{1, 2}, keys)
+	d.Close()
+}
+
+func TestReentrantCallback(t *testing.T) {
+	d := New[int, int](0, 0, nil)
+	var keys []int
+	var callback types.OnEvictCallback[int, int]
+	callback = func(key int, _ int, _ types.EvictReason) {
+		keys = append(keys, key)
+		if key == 1 {
+			// the evictions caused by the callback are dispatched before it returns
+			d.Dispatch(callback, evictions(3))
+			assert.Equal(t, []int{1, 3}, keys)
+		}
+	}
+
+	d.Dispatch(callback, evictions(1, 2))
+	assert.Equal(t, []int{1, 3, 2}, keys)
+	d.Close()
+}
+
+func TestPanicRecovery(t *testing.T) {
+	var recovered []string
+	d := New[int, int](0, 0, func(key int, _ int, _ types.EvictReason, r any) {
+		recovered = append(recovered, fmt.Sprintf("%d: %v", key, r))
+	})
+	var keys []int
+	d.Dispatch(func(key int, _ int, _ types.EvictReason) {
+		keys = append(keys, key)
+		panic("boom")
+	}, evictions(1, 2))
+
+	// the panics are reported, and don't stop the next callbacks
+	assert.Equal(t, []int{1, 2}, keys)
+	assert.Equal(t, []string{"1: boom", "2: boom"}, recovered)
+	d.Close()
+}
+
+func TestAsynchronous(t *testing.T) {
+	d := New[int, int](4, 1, nil)
+	var mu sync.Mutex
+	keys := make(map[int][]int)
+	callback := func(key int, value int, _ types.EvictReason) {
+		mu.Lock()
+		keys[key] = append(keys[key], value)
+		mu.Unlock()
+	}
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, d.Enqueue(callback, []Eviction[int, int]{{Key: i % 10, Value: i}}))
+	}
+	d.Close()
+
+	// the evictions of the same key are handled in order
+	for key, values := range keys {
+		assert.Equal(t, 10, len(values))
+		for i, value := range values {
+			assert.Equal(t, key+i*10, value)
+		}
+	}
+	assert.Equal(t, 10, len(keys))
+
+	// the evictions enqueued after closing are left to the caller
+	assert.False(t, d.Enqueue(callback, evictions(100)))
+}
+
+func TestReentrantAsynchronous(t *testing.T) {
+	d := New[int, int](1, 0, nil)
+	done := make(chan struct{})
+	var mu sync.Mutex
+	var keys []int
+	var callback types.OnEvictCallback[int, int]
+	callback = func(key int, _ int, _ types.EvictReason) {
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+		if key == 1 {
+			// the worker only queues the evictions caused by the callback
+			d.Enqueue(callback, evictions(2, 3))
+			close(done)
+		}
+	}
+
+	d.Enqueue(callback, evictions(1))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the worker is blocked enqueuing its own evictions")
+	}
+	d.Close()
+
+	assert.Equal(t, []int{1, 2, 3}, keys)
+}
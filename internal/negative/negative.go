@@ -0,0 +1,97 @@
+// Package negative holds the failed loads cached by the caches, apart from their entries,
+// so that they never take the place of a real value.
+package negative
+
+import (
+	"container/list"
+	"time"
+)
+
+type entry[K comparable] struct {
+	key       K
+	err       error
+	expiredAt time.Time
+}
+
+// Cache is a FIFO of failed loads bounded by the number of keys, expiring each one at its own deadline.
+// It's not safe for concurrent use, the caches call it with their lock held.
+// A nil *Cache holds nothing, so that the caches don't have to check whether negative caching is enabled.
+type Cache[K comparable] struct {
+	size  int
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+// New creates a cache holding up to size failed loads.
+func New[K comparable](size int) *Cache[K] {
+	return &Cache[K]{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the error of the failed load of the key, or nil if there is none or it has expired at now.
+// An expired failure is removed.
+func (c *Cache[K]) Get(key K, now time.Time) error {
+	if c == nil {
+		return nil
+	}
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	e := el.Value.(*entry[K])
+	if !now.Before(e.expiredAt) {
+		c.remove(el)
+		return nil
+	}
+	return e.err
+}
+
+// Set records the failed load of the key with a non-nil err until expiredAt, replacing the previous one.
+// The oldest failures are dropped to make room for it.
+func (c *Cache[K]) Set(key K, err error, expiredAt time.Time) {
+	c.Remove(key)
+
+	for c.ll.Len() >= c.size {
+		c.remove(c.ll.Back())
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K]{key: key, err: err, expiredAt: expiredAt})
+}
+
+// Remove removes the failed load of the key, if any.
+func (c *Cache[K]) Remove(key K) {
+	if c == nil {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns the number of failed loads, including the expired ones not removed yet.
+func (c *Cache[K]) Len() int {
+	if c == nil {
+		return 0
+	}
+	return c.ll.Len()
+}
+
+// Clear removes all the failed loads.
+func (c *Cache[K]) Clear() {
+	if c == nil {
+		return
+	}
+	clear(c.items)
+	c.ll.Init()
+}
+
+func (c *Cache[K]) remove(el *list.Element) {
+	delete(c.items, el.Value.(*entry[K]).key)
+	c.ll.Remove(el)
+}
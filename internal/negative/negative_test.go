@@ -0,0 +1,51 @@
+package negative
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+)
+
+func TestGetAndSet(t *testing.T) {
+	now := time.Now()
+	errNotFound := errors.New("not found")
+	c := New[int](10)
+
+	c.Set(1, errNotFound, now.Add(time.Second))
+	assert.Equal(t, errNotFound, c.Get(1, now))
+	assert.NoError(t, c.Get(2, now))
+
+	// an expired failure is removed
+	assert.NoError(t, c.Get(1, now.Add(time.Second)))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestSize(t *testing.T) {
+	now := time.Now()
+	c := New[int](2)
+
+	c.Set(1, errors.New("1"), now.Add(time.Second))
+	c.Set(2, errors.New("2"), now.Add(time.Second))
+	c.Set(1, errors.New("1"), now.Add(time.Second))
+	c.Set(3, errors.New("3"), now.Add(time.Second))
+	assert.Equal(t, 2, c.Len())
+
+	// the oldest failure is dropped first
+	assert.NoError(t, c.Get(2, now))
+	assert.Error(t, c.Get(1, now))
+
+	c.Remove(1)
+	assert.Equal(t, 1, c.Len())
+	c.Clear()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestNil(t *testing.T) {
+	var c *Cache[int]
+	assert.NoError(t, c.Get(1, time.Now()))
+	c.Remove(1)
+	c.Clear()
+	assert.Equal(t, 0, c.Len())
+}
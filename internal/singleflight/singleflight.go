@@ -0,0 +1,93 @@
+// Package singleflight provides a duplicate call suppression mechanism
+// used by the loading caches to coalesce concurrent misses for the same key.
+package singleflight
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// call is an in-flight or completed loader call.
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+
+	// waiters is the number of callers still waiting for the result.
+	waiters int
+
+	// cancel cancels the context passed to the loader.
+	cancel context.CancelFunc
+}
+
+// Group coalesces concurrent calls for the same key into a single execution.
+// The zero value is ready to use.
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Do executes fn for the given key, making sure that only one execution is
+// in-flight for a key at a time. Callers arriving while an execution is
+// in-flight wait for it and receive the same result.
+//
+// Each caller stops waiting when its own context is done and returns the context error.
+// The context passed to fn is only canceled once every caller has stopped waiting.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+
+	c, ok := g.calls[key]
+	if !ok {
+		fnCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call[V]{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		g.calls[key] = c
+		go g.run(fnCtx, key, c, fn)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// nobody is interested in the result anymore
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *Group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(ctx context.Context) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("singleflight: loader panicked: %v", r)
+		}
+
+		g.mu.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+
+		c.cancel()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn(ctx)
+}
@@ -0,0 +1,101 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+)
+
+func TestDo(t *testing.T) {
+	var g Group[string, int]
+	v, err := g.Do(context.Background(), "key", func(context.Context) (int, error) {
+		return 10, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, v)
+}
+
+func TestDoDeduplicates(t *testing.T) {
+	var g Group[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do(context.Background(), "key", func(context.Context) (int, error) {
+				calls.Add(1)
+				<-release
+				return 10, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 10, v)
+		}()
+	}
+
+	// wait until every caller joined the in-flight call
+	for {
+		g.mu.Lock()
+		c := g.calls["key"]
+		joined := c != nil && c.waiters == 100
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestDoPropagatesError(t *testing.T) {
+	var g Group[string, int]
+	errLoad := errors.New("load failed")
+	_, err := g.Do(context.Background(), "key", func(context.Context) (int, error) {
+		return 0, errLoad
+	})
+	assert.True(t, errors.Is(err, errLoad))
+}
+
+func TestDoRecoversPanic(t *testing.T) {
+	var g Group[string, int]
+	_, err := g.Do(context.Background(), "key", func(context.Context) (int, error) {
+		panic("boom")
+	})
+	assert.Error(t, err)
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	var g Group[string, int]
+	loaderCtx := make(chan context.Context, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := g.Do(ctx, "key", func(ctx context.Context) (int, error) {
+		loaderCtx <- ctx
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// the loader is canceled as soon as no caller waits for it
+	select {
+	case c := <-loaderCtx:
+		<-c.Done()
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
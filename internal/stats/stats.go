@@ -0,0 +1,117 @@
+// Package stats provides lock-free counters backing the Stats method of the caches.
+package stats
+
+import (
+	"sync/atomic"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Counter counts the cache events with atomic operations, so it can be always-on,
+// and reports them to the observer set with Observe.
+// The zero value is ready to use.
+type Counter struct {
+	observer types.Observer
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	sets      atomic.Uint64
+	updates   atomic.Uint64
+	evictions [types.NumEvictReasons]atomic.Uint64
+	ghostHits atomic.Uint64
+
+	negativeHits atomic.Uint64
+	negativeSets atomic.Uint64
+	rejections   atomic.Uint64
+}
+
+// Observe reports the events counted from now on to the observer.
+// It must be called before the counter is shared.
+func (c *Counter) Observe(observer types.Observer) {
+	c.observer = observer
+}
+
+func (c *Counter) Hit() {
+	c.hits.Add(1)
+	if c.observer != nil {
+		c.observer.OnGet(true)
+	}
+}
+
+func (c *Counter) Miss() {
+	c.misses.Add(1)
+	if c.observer != nil {
+		c.observer.OnGet(false)
+	}
+}
+
+func (c *Counter) Set() {
+	c.sets.Add(1)
+	if c.observer != nil {
+		c.observer.OnSet(false)
+	}
+}
+
+func (c *Counter) Update() {
+	c.updates.Add(1)
+	if c.observer != nil {
+		c.observer.OnSet(true)
+	}
+}
+
+func (c *Counter) Evict(reason types.EvictReason) {
+	c.evictions[reason].Add(1)
+	if c.observer != nil {
+		c.observer.OnEvict(reason)
+	}
+}
+
+func (c *Counter) GhostHit() {
+	c.ghostHits.Add(1)
+}
+
+func (c *Counter) NegativeHit() {
+	c.negativeHits.Add(1)
+}
+
+func (c *Counter) NegativeSet() {
+	c.negativeSets.Add(1)
+}
+
+func (c *Counter) Reject() {
+	c.rejections.Add(1)
+}
+
+// Snapshot returns the current values of the counters.
+func (c *Counter) Snapshot() types.Stats {
+	s := types.Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Updates:   c.updates.Load(),
+		GhostHits: c.ghostHits.Load(),
+
+		NegativeHits: c.negativeHits.Load(),
+		NegativeSets: c.negativeSets.Load(),
+		Rejections:   c.rejections.Load(),
+	}
+	for i := range c.evictions {
+		s.Evictions[i] = c.evictions[i].Load()
+	}
+	return s
+}
+
+// Reset sets all the counters to zero.
+func (c *Counter) Reset() {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.sets.Store(0)
+	c.updates.Store(0)
+	c.ghostHits.Store(0)
+	c.negativeHits.Store(0)
+	c.negativeSets.Store(0)
+	c.rejections.Store(0)
+	for i := range c.evictions {
+		c.evictions[i].Store(0)
+	}
+}
@@ -0,0 +1,212 @@
+// Package timerwheel provides a hierarchical timing wheel used by the caches to expire entries.
+//
+// Scheduling and canceling a timer are O(1), and advancing the wheel only visits
+// the slots whose time has come, so it scales to millions of timers with mixed deadlines.
+// Timers are expired as soon as the wheel is advanced past their deadline, and never before it.
+package timerwheel
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// slotBits is the number of bits of the tick count indexing the slots of a level.
+	slotBits = 6
+
+	// numberOfSlots is the number of slots per level.
+	numberOfSlots = 1 << slotBits
+
+	// numberOfLevels is the number of levels of the wheel.
+	// The highest level spans numberOfSlots^numberOfLevels ticks,
+	// timers beyond it are checked again every round of the highest level.
+	numberOfLevels = 4
+
+	slotMask = numberOfSlots - 1
+)
+
+// maxDeadline is the latest deadline representable in nanoseconds since the Unix epoch,
+// later deadlines are treated as never reached.
+var maxDeadline = time.Unix(0, math.MaxInt64)
+
+// Timer is an element of the wheel, meant to be embedded in the value it expires.
+// The zero value is an unscheduled timer.
+type Timer[T any] struct {
+	// Value is returned by Advance when the timer expires.
+	Value T
+
+	// deadline is the expiration time in nanoseconds.
+	deadline int64
+
+	prev *Timer[T]
+	next *Timer[T]
+}
+
+// Scheduled reports whether the timer is scheduled in a wheel.
+func (t *Timer[T]) Scheduled() bool {
+	return t.next != nil
+}
+
+// Wheel is a hierarchical timing wheel. It is not safe for concurrent use.
+type Wheel[T any] struct {
+	// tick is the duration of a slot in the lowest level.
+	tick int64
+
+	// current is the time of the wheel in ticks.
+	current int64
+
+	// levels holds the sentinels of the circular lists of timers in each slot.
+	levels [numberOfLevels][numberOfSlots]Timer[T]
+
+	// due is the sentinel of the list of timers whose deadline falls in the current tick,
+	// checked on every advance, so that they don't wait for the next tick to expire.
+	due Timer[T]
+
+	// len is the number of scheduled timers.
+	len int
+}
+
+// New creates a wheel advancing by ticks of the given duration, starting at now.
+func New[T any](tick time.Duration, now time.Time) *Wheel[T] {
+	if tick <= 0 {
+		panic("timerwheel: tick must be greater than 0")
+	}
+
+	w := &Wheel[T]{
+		tick: int64(tick),
+	}
+	w.current = w.ticks(now.UnixNano())
+	w.Clear()
+	return w
+}
+
+// Len returns the number of scheduled timers.
+func (w *Wheel[T]) Len() int {
+	return w.len
+}
+
+// Schedule schedules the timer to expire at the deadline.
+// If the timer is already scheduled, it is rescheduled.
+func (w *Wheel[T]) Schedule(t *Timer[T], deadline time.Time) {
+	w.Cancel(t)
+	t.deadline = math.MaxInt64
+	if deadline.Before(maxDeadline) {
+		t.deadline = deadline.UnixNano()
+	}
+	w.insert(t)
+	w.len++
+}
+
+// Cancel removes the timer from the wheel. It's a no-op if the timer is not scheduled.
+func (w *Wheel[T]) Cancel(t *Timer[T]) {
+	if !t.Scheduled() {
+		return
+	}
+	unlink(t)
+	w.len--
+}
+
+// Advance moves the wheel forward to now, and returns the values of the timers
+// whose deadline has passed. The expired timers are unscheduled.
+func (w *Wheel[T]) Advance(now time.Time) (expired []T) {
+	nanos := now.UnixNano()
+	target := w.ticks(nanos)
+	if target > w.current {
+		previous := w.current
+		w.current = target
+
+		for level := 0; level < numberOfLevels; level++ {
+			shift := uint(level * slotBits)
+			from, to := previous>>shift, target>>shift
+			if from == to {
+				// the higher levels have not moved either
+				break
+			}
+
+			// visit the slots reached since the last advance, at most once each
+			steps := min(to-from, numberOfSlots)
+			for i := int64(1); i <= steps; i++ {
+				expired = w.expire(&w.levels[level][(from+i)&slotMask], nanos, expired)
+			}
+		}
+	}
+
+	// the timers due in the previous ticks all expire, the ones due later in the current tick stay
+	return w.expire(&w.due, nanos, expired)
+}
+
+// Clear unschedules all the timers.
+func (w *Wheel[T]) Clear() {
+	for level := range w.levels {
+		for slot := range w.levels[level] {
+			clearList(&w.levels[level][slot])
+		}
+	}
+	clearList(&w.due)
+	w.len = 0
+}
+
+// clearList unschedules the timers of the list.
+func clearList[T any](sentinel *Timer[T]) {
+	for t := sentinel.next; t != nil && t != sentinel; {
+		next := t.next
+		t.prev, t.next = nil, nil
+		t = next
+	}
+	sentinel.prev, sentinel.next = sentinel, sentinel
+}
+
+// expire detaches the timers of the slot, appends the values of the expired ones
+// to expired and moves the others down to the slot matching their deadline.
+func (w *Wheel[T]) expire(sentinel *Timer[T], now int64, expired []T) []T {
+	if sentinel.next == sentinel {
+		return expired
+	}
+
+	// detach the list, as the timers may be rescheduled into the same slot
+	var detached Timer[T]
+	detached.next, detached.prev = sentinel.next, sentinel.prev
+	detached.next.prev, detached.prev.next = &detached, &detached
+	sentinel.prev, sentinel.next = sentinel, sentinel
+
+	for t := detached.next; t != &detached; t = detached.next {
+		unlink(t)
+		if t.deadline <= now {
+			expired = append(expired, t.Value)
+			w.len--
+			continue
+		}
+		w.insert(t)
+	}
+
+	return expired
+}
+
+// insert links the timer into the slot matching its deadline,
+// or into the due list if its deadline falls in the current tick.
+func (w *Wheel[T]) insert(t *Timer[T]) {
+	deadline := w.ticks(t.deadline)
+	sentinel := &w.due
+	if delta := deadline - w.current; delta > 0 {
+		level := 0
+		for level < numberOfLevels-1 && delta >= 1<<uint((level+1)*slotBits) {
+			level++
+		}
+		sentinel = &w.levels[level][(deadline>>uint(level*slotBits))&slotMask]
+	}
+
+	t.prev, t.next = sentinel.prev, sentinel
+	sentinel.prev.next = t
+	sentinel.prev = t
+}
+
+// ticks converts nanoseconds into ticks.
+func (w *Wheel[T]) ticks(nanos int64) int64 {
+	return nanos / w.tick
+}
+
+func unlink[T any](t *Timer[T]) {
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.prev, t.next = nil, nil
+}
@@ -0,0 +1,148 @@
+package timerwheel
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+)
+
+func TestAdvance(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := New[int](time.Second, start)
+
+	timers := make([]Timer[int], 3)
+	for i := range timers {
+		timers[i].Value = i
+	}
+	w.Schedule(&timers[0], start.Add(time.Second))
+	w.Schedule(&timers[1], start.Add(time.Minute))
+	w.Schedule(&timers[2], start.Add(time.Hour))
+	assert.Equal(t, 3, w.Len())
+
+	assert.Equal(t, 0, len(w.Advance(start.Add(999*time.Millisecond))))
+	assert.Equal(t, []int{0}, w.Advance(start.Add(time.Second)))
+	assert.Equal(t, []int{1}, w.Advance(start.Add(time.Minute)))
+	assert.Equal(t, 0, len(w.Advance(start.Add(time.Hour-time.Second))))
+	assert.Equal(t, []int{2}, w.Advance(start.Add(time.Hour)))
+	assert.Equal(t, 0, w.Len())
+	assert.False(t, timers[0].Scheduled())
+}
+
+func TestAdvanceWithinTick(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := New[int](time.Second, start)
+
+	// the timers due in the current tick expire as soon as their deadline passes
+	var t1, t2 Timer[int]
+	t1.Value, t2.Value = 1, 2
+	w.Schedule(&t1, start.Add(100*time.Millisecond))
+	w.Schedule(&t2, start.Add(1500*time.Millisecond))
+
+	assert.Equal(t, 0, len(w.Advance(start.Add(99*time.Millisecond))))
+	assert.Equal(t, []int{1}, w.Advance(start.Add(500*time.Millisecond)))
+	assert.Equal(t, 0, len(w.Advance(start.Add(1499*time.Millisecond))))
+	assert.Equal(t, []int{2}, w.Advance(start.Add(1500*time.Millisecond)))
+	assert.Equal(t, 0, w.Len())
+}
+
+func TestCancelAndReschedule(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := New[int](time.Second, start)
+
+	var t1, t2 Timer[int]
+	t1.Value, t2.Value = 1, 2
+	w.Schedule(&t1, start.Add(time.Second))
+	w.Schedule(&t2, start.Add(time.Second))
+
+	w.Cancel(&t1)
+	w.Cancel(&t1) // canceling twice is a no-op
+	w.Schedule(&t2, start.Add(time.Hour))
+	assert.Equal(t, 1, w.Len())
+
+	assert.Equal(t, 0, len(w.Advance(start.Add(time.Minute))))
+	assert.Equal(t, []int{2}, w.Advance(start.Add(2*time.Hour)))
+}
+
+func TestClear(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := New[int](time.Second, start)
+
+	var t1 Timer[int]
+	w.Schedule(&t1, start.Add(time.Second))
+	w.Clear()
+	assert.Equal(t, 0, w.Len())
+	assert.False(t, t1.Scheduled())
+	assert.Equal(t, 0, len(w.Advance(start.Add(time.Hour))))
+}
+
+func TestBeyondHighestLevel(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := New[int](time.Millisecond, start)
+
+	// the highest level spans about 4.6 hours of milliseconds
+	var t1 Timer[int]
+	deadline := start.Add(100 * time.Hour)
+	w.Schedule(&t1, deadline)
+
+	now := start
+	for now.Before(deadline) {
+		now = now.Add(time.Hour)
+		expired := w.Advance(now)
+		if now.Before(deadline) {
+			assert.Equal(t, 0, len(expired))
+		} else {
+			assert.Equal(t, 1, len(expired))
+		}
+	}
+}
+
+func TestRandomDeadlines(t *testing.T) {
+	tick := 10 * time.Millisecond
+	start := time.Unix(1700000000, 0)
+	w := New[int](tick, start)
+
+	deadlines := make([]time.Time, 10000)
+	timers := make([]Timer[int], len(deadlines))
+	for i := range timers {
+		deadlines[i] = start.Add(time.Duration(rand.Int64N(int64(time.Hour))))
+		timers[i].Value = i
+		w.Schedule(&timers[i], deadlines[i])
+	}
+
+	// advance by irregular steps, checking that no timer expires early or more than a step late
+	now := start
+	count := 0
+	for w.Len() > 0 {
+		step := time.Duration(rand.Int64N(int64(5 * time.Second)))
+		previous := now
+		now = now.Add(step)
+		for _, i := range w.Advance(now) {
+			assert.False(t, deadlines[i].After(now), "expired early")
+			assert.True(t, deadlines[i].After(previous.Add(-tick)), "expired late")
+			count++
+		}
+	}
+	assert.Equal(t, len(timers), count)
+}
+
+func TestFarFutureDeadline(t *testing.T) {
+	start := time.Now()
+	w := New[int](time.Second, start)
+
+	// a deadline beyond the year 2262 overflows UnixNano
+	var t1, t2 Timer[int]
+	t1.Value, t2.Value = 1, 2
+	w.Schedule(&t1, start.Add(math.MaxInt64))
+	w.Schedule(&t2, time.Date(3000, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	// the timers survive several rounds of the highest level
+	for i := 1; i <= 4; i++ {
+		assert.Equal(t, 0, len(w.Advance(start.Add(time.Duration(i)*365*24*time.Hour))))
+	}
+	assert.Equal(t, 2, w.Len())
+	assert.True(t, t1.Scheduled())
+	assert.True(t, t2.Scheduled())
+}
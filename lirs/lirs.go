@@ -0,0 +1,45 @@
+// Package lirs implements the LIRS (Low Inter-reference Recency Set) cache, evicting the keys
+// whose accesses are the farthest apart, which holds up on loops over more keys than the cache.
+//
+// LIRS tells the keys accessed regularly from the ones accessed once by the number of other keys
+// accessed between their last two accesses, which it tracks in a stack including recently evicted keys.
+// Every hit takes the write lock. See [LIRS: An Efficient Low Inter-reference Recency Set Replacement Policy]
+// for the details.
+//
+// [LIRS: An Efficient Low Inter-reference Recency Set Replacement Policy]: https://dl.acm.org/doi/10.1145/511399.511340
+package lirs
+
+import (
+	"time"
+
+	"github.com/scalalang2/golang-fifo/internal/cache"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// LIRS is a low inter-reference recency set cache. Peek, Contains and iterating over the cache
+// don't count as accesses.
+type LIRS[K comparable, V any] struct {
+	*cache.Cache[K, V]
+}
+
+var (
+	_ types.StaleLoadingCache[int, int] = (*LIRS[int, int])(nil)
+	_ types.BatchCache[int, int]        = (*LIRS[int, int])(nil)
+	_ types.AtomicCache[int, int]       = (*LIRS[int, int])(nil)
+)
+
+// New creates a cache holding up to size entries, with the given default TTL.
+// Zero ttl means no expiration. It panics if size is not positive.
+func New[K comparable, V any](size int, ttl time.Duration) *LIRS[K, V] {
+	return &LIRS[K, V]{cache.Must(cache.New("lirs", size, newPolicy[K](), types.WithTTL[K, V](ttl)))}
+}
+
+// NewWithOptions creates a cache holding up to size entries, configured with the given options.
+// It returns an error if the options are invalid.
+func NewWithOptions[K comparable, V any](size int, opts ...types.Option[K, V]) (*LIRS[K, V], error) {
+	c, err := cache.New("lirs", size, newPolicy[K](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &LIRS[K, V]{c}, nil
+}
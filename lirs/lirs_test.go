@@ -0,0 +1,122 @@
+package lirs
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+const noEvictionTTL = 0
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
+func TestLIRSPolicy(t *testing.T) {
+	cache := New[int, int](100, noEvictionTTL)
+	defer cache.Close()
+
+	// a loop over more keys than the cache
+	var hits int
+	for pass := 0; pass < 10; pass++ {
+		hits = 0
+		for i := 0; i < 150; i++ {
+			if _, ok := cache.Get(i); ok {
+				hits++
+			} else {
+				cache.Set(i, i)
+			}
+		}
+	}
+
+	// the lir keys keep hitting, where LRU would always miss
+	assert.True(t, hits >= 90)
+}
+
+func TestPolicy(t *testing.T) {
+	p := newPolicy[int]()
+	p.Resize(4)
+	var evicted []int
+	evict := func(key int) {
+		evicted = append(evicted, key)
+	}
+	add := func(key int) {
+		if p.lirs+p.queue.Len() == 4 {
+			p.Evict(&key, evict)
+		}
+		p.Add(key, 1)
+	}
+
+	// the first keys are lir keys, then a single hir key
+	for i := 1; i <= 4; i++ {
+		add(i)
+	}
+	assert.Equal(t, 3, p.lirs)
+	assert.Equal(t, hir, p.nodes[4].status)
+
+	// the hir key is evicted, but stays in the stack
+	add(5)
+	assert.Equal(t, []int{4}, evicted)
+	assert.Equal(t, nonResident, p.nodes[4].status)
+
+	// accessed again, it becomes a lir key and the bottom lir key a hir one
+	add(4)
+	assert.Equal(t, []int{4, 5}, evicted)
+	assert.Equal(t, lir, p.nodes[4].status)
+	assert.Equal(t, hir, p.nodes[1].status)
+	assert.Equal(t, 3, p.lirs)
+
+	// the stack is pruned down to the bottom lir key
+	assert.Equal(t, 2, p.stack.Back().Value.(*node[int]).key)
+	assert.True(t, p.nodes[1].stack == nil)
+
+	// a hit on a lir key doesn't evict
+	p.Hit(2)
+	assert.Equal(t, 3, p.stack.Back().Value.(*node[int]).key)
+	assert.Equal(t, []int{4, 5}, evicted)
+}
+
+func TestPolicyInvariants(t *testing.T) {
+	for _, size := range []int{1, 2, 16} {
+		p := newPolicy[int]()
+		p.Resize(int64(size))
+		resident := make(map[int]bool)
+		evict := func(key int) {
+			delete(resident, key)
+		}
+		add := func(key int) {
+			if p.lirs+p.queue.Len() == size {
+				p.Evict(&key, evict)
+			}
+			p.Add(key, 1)
+		}
+
+		rng := rand.New(rand.NewPCG(1, 2))
+		for i := 0; i < 100000; i++ {
+			key := rng.IntN(4 * size)
+			switch {
+			case resident[key] && rng.IntN(10) == 0:
+				p.Remove(key)
+				delete(resident, key)
+			case resident[key]:
+				p.Hit(key)
+			default:
+				add(key)
+				resident[key] = true
+			}
+
+			assert.Equal(t, len(resident), p.lirs+p.queue.Len())
+			assert.True(t, p.lirWeight <= p.lirSize)
+			assert.True(t, p.ghosts.Len() <= size)
+			assert.Equal(t, len(resident)+p.ghosts.Len(), len(p.nodes))
+			if back := p.stack.Back(); back != nil && p.lirs > 0 {
+				assert.Equal(t, lir, back.Value.(*node[int]).status)
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package lirs
+
+import "container/list"
+
+// status is the status of a key.
+type status uint8
+
+const (
+	lir         status = iota // resident key with a low inter-reference recency
+	hir                       // resident key with a high inter-reference recency
+	nonResident               // evicted key still tracked by the stack
+)
+
+// node is a key tracked by the policy.
+type node[K comparable] struct {
+	key    K
+	weight int64
+	status status
+
+	// stack is the element of the key in the stack, or nil if it's not in the stack
+	stack *list.Element
+
+	// queue is the element of the key in the queue of the resident hir keys,
+	// or in the ghost queue if the key is non-resident
+	queue *list.Element
+}
+
+// policy is the LIRS eviction policy.
+//
+// The keys with a low inter-reference recency (IRR), the number of other keys accessed
+// between their last two accesses, are LIR keys and make up most of the cache.
+// The other resident keys are HIR keys, held in a small FIFO queue, and are evicted first.
+//
+// The stack orders the recently accessed keys by recency, and is pruned so that its bottom
+// is the least recent LIR key. A HIR key accessed while still in the stack has a lower IRR
+// than this LIR key: it becomes a LIR key and the bottom LIR key a HIR one. This way,
+// a loop over more keys than the cache keeps hitting the LIR keys, where LRU always misses.
+//
+// The sizes are counted in weight, the non-resident keys keeping the weight they had.
+type policy[K comparable] struct {
+	nodes  map[K]*node[K]
+	stack  *list.List // front is the most recent key
+	queue  *list.List // front is the next hir key to evict
+	ghosts *list.List // front is the oldest non-resident key
+
+	// lirs is the number of lir keys, and lirWeight their weight, up to lirSize
+	lirs      int
+	lirWeight int64
+	lirSize   int64
+
+	// ghostWeight is the weight of the non-resident keys
+	ghostWeight int64
+
+	// size is the capacity of the cache, and also bounds the weight of the non-resident keys
+	size int64
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	return &policy[K]{
+		nodes:  make(map[K]*node[K]),
+		stack:  list.New(),
+		queue:  list.New(),
+		ghosts: list.New(),
+	}
+}
+
+func (p *policy[K]) Add(key K, weight int64) bool {
+	// the non-resident keys are bounded, as the stack could otherwise grow without limit
+	defer p.forgetGhosts()
+
+	n, ok := p.nodes[key]
+	if ok {
+		// the key is non-resident but still in the stack, so its IRR is low
+		p.ghosts.Remove(n.queue)
+		p.ghostWeight -= n.weight
+		n.queue = nil
+		n.weight = weight
+		p.stack.MoveToFront(n.stack)
+		p.promote(n)
+		return true
+	}
+
+	n = &node[K]{key: key, weight: weight}
+	p.nodes[key] = n
+	n.stack = p.stack.PushFront(n)
+	if p.lirWeight+weight <= p.lirSize {
+		n.status = lir
+		p.lirs++
+		p.lirWeight += weight
+		p.prune() // as in promote
+		return false
+	}
+	n.status = hir
+	n.queue = p.queue.PushBack(n)
+	return false
+}
+
+// Evict evicts the hir key at the front of the queue, demoting the bottom lir key first if there is none.
+// It stays in the stack as a non-resident key, in case it's accessed again soon.
+func (p *policy[K]) Evict(_ *K, evict func(key K)) {
+	if p.queue.Len() == 0 {
+		p.demote()
+	}
+
+	n := p.queue.Remove(p.queue.Front()).(*node[K])
+	n.queue = nil
+	if n.stack == nil {
+		delete(p.nodes, n.key)
+	} else {
+		n.status = nonResident
+		n.queue = p.ghosts.PushBack(n)
+		p.ghostWeight += n.weight
+	}
+	evict(n.key)
+}
+
+// Victim returns the key the next eviction would remove.
+func (p *policy[K]) Victim(K) (key K, ok bool) {
+	if front := p.queue.Front(); front != nil {
+		return front.Value.(*node[K]).key, true
+	}
+	if p.lirs > 0 {
+		return p.stack.Back().Value.(*node[K]).key, true
+	}
+	return key, false
+}
+
+func (p *policy[K]) Hit(key K) {
+	n := p.nodes[key]
+	switch {
+	case n.status == lir:
+		p.stack.MoveToFront(n.stack)
+		p.prune()
+	case n.stack != nil:
+		// the hir key is accessed again before the bottom lir key
+		p.stack.MoveToFront(n.stack)
+		p.queue.Remove(n.queue)
+		n.queue = nil
+		p.promote(n)
+	default:
+		n.stack = p.stack.PushFront(n)
+		p.queue.MoveToBack(n.queue)
+	}
+}
+
+func (p *policy[K]) Update(key K, weight int64) {
+	n := p.nodes[key]
+	if n.status == lir {
+		p.lirWeight += weight - n.weight
+	}
+	n.weight = weight
+	p.balance()
+}
+
+// promote makes the key at the top of the stack a lir key,
+// demoting the bottom lir keys to hir keys if they're too heavy.
+func (p *policy[K]) promote(n *node[K]) {
+	n.status = lir
+	p.lirs++
+	p.lirWeight += n.weight
+
+	// the keys below the new lir key are hir keys if there was no other lir key,
+	// as the removed keys left none or the cache is too small to hold any
+	p.prune()
+	p.balance()
+}
+
+// balance demotes the bottom lir keys until the lir keys fit in lirSize.
+func (p *policy[K]) balance() {
+	for p.lirWeight > p.lirSize {
+		p.demote()
+	}
+}
+
+// demote makes the bottom lir key a hir key, at the back of the queue.
+func (p *policy[K]) demote() {
+	bottom := p.stack.Remove(p.stack.Back()).(*node[K])
+	bottom.stack = nil
+	bottom.status = hir
+	bottom.queue = p.queue.PushBack(bottom)
+	p.lirs--
+	p.lirWeight -= bottom.weight
+	p.prune()
+}
+
+// prune removes the hir and non-resident keys at the bottom of the stack,
+// so that its bottom is a lir key.
+func (p *policy[K]) prune() {
+	for back := p.stack.Back(); back != nil; back = p.stack.Back() {
+		n := back.Value.(*node[K])
+		if n.status == lir {
+			return
+		}
+		if n.status == nonResident {
+			p.forget(n)
+			continue
+		}
+		p.stack.Remove(back)
+		n.stack = nil
+	}
+}
+
+// forgetGhosts forgets the oldest non-resident keys until their weight fits in size.
+func (p *policy[K]) forgetGhosts() {
+	for p.ghosts.Len() > 0 && p.ghostWeight > p.size {
+		p.forget(p.ghosts.Front().Value.(*node[K]))
+	}
+}
+
+// forget removes a non-resident key.
+func (p *policy[K]) forget(n *node[K]) {
+	p.ghosts.Remove(n.queue)
+	p.ghostWeight -= n.weight
+	p.stack.Remove(n.stack)
+	delete(p.nodes, n.key)
+}
+
+func (p *policy[K]) Remove(key K) {
+	n := p.nodes[key]
+	if n.status == lir {
+		p.lirs--
+		p.lirWeight -= n.weight
+	} else {
+		p.queue.Remove(n.queue)
+	}
+	if n.stack != nil {
+		p.stack.Remove(n.stack)
+	}
+	delete(p.nodes, key)
+	p.prune()
+}
+
+func (p *policy[K]) Resize(capacity int64) {
+	// the hir keys take 1% of the cache
+	hirSize := max(capacity/100, 1)
+	p.lirSize = capacity - hirSize
+	p.size = capacity
+
+	p.balance()
+	p.forgetGhosts()
+}
+
+func (p *policy[K]) Reset() {
+	p.stack.Init()
+	p.queue.Init()
+	p.ghosts.Init()
+	clear(p.nodes)
+	p.lirs = 0
+	p.lirWeight = 0
+	p.ghostWeight = 0
+}
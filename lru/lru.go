@@ -0,0 +1,42 @@
+// Package lru implements the LRU (Least Recently Used) cache, as a baseline to compare
+// the eviction policies of the other packages with.
+//
+// Every hit moves the key to the front of a list, so that hits take the write lock,
+// and the key at the back is evicted. LRU does well when the recently used keys are
+// the most likely to be used again, but a scan of one-off keys flushes the whole cache.
+package lru
+
+import (
+	"time"
+
+	"github.com/scalalang2/golang-fifo/internal/cache"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// LRU is a least recently used cache. Peek, Contains and iterating over the cache
+// don't update the recency of the entries.
+type LRU[K comparable, V any] struct {
+	*cache.Cache[K, V]
+}
+
+var (
+	_ types.StaleLoadingCache[int, int] = (*LRU[int, int])(nil)
+	_ types.BatchCache[int, int]        = (*LRU[int, int])(nil)
+	_ types.AtomicCache[int, int]       = (*LRU[int, int])(nil)
+)
+
+// New creates a cache holding up to size entries, with the given default TTL.
+// Zero ttl means no expiration. It panics if size is not positive.
+func New[K comparable, V any](size int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{cache.Must(cache.New("lru", size, newPolicy[K](), types.WithTTL[K, V](ttl)))}
+}
+
+// NewWithOptions creates a cache holding up to size entries, configured with the given options.
+// It returns an error if the options are invalid.
+func NewWithOptions[K comparable, V any](size int, opts ...types.Option[K, V]) (*LRU[K, V], error) {
+	c, err := cache.New("lru", size, newPolicy[K](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &LRU[K, V]{c}, nil
+}
@@ -0,0 +1,96 @@
+package lru
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/fakeclock"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+const noEvictionTTL = 0
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
+func TestLRUPolicy(t *testing.T) {
+	cache := New[int, int](3, noEvictionTTL)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+
+	// the least recently used key is evicted
+	cache.Get(1)
+	cache.Set(4, 4)
+	assert.False(t, cache.Contains(2))
+
+	// updating a key uses it
+	cache.Set(3, 30)
+	cache.Set(5, 5)
+	assert.False(t, cache.Contains(1))
+	assert.True(t, cache.Contains(3))
+	assert.True(t, cache.Contains(4))
+	assert.True(t, cache.Contains(5))
+}
+
+func TestPeekDoesNotUpdateRecency(t *testing.T) {
+	cache := New[int, int](3, noEvictionTTL)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	cache.Set(3, 3)
+
+	value, ok := cache.Peek(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.True(t, cache.Contains(1))
+
+	cache.Set(4, 4)
+	assert.False(t, cache.Contains(1))
+	assert.True(t, cache.Contains(2))
+}
+
+func TestRefreshDoesNotUpdateRecency(t *testing.T) {
+	clock := fakeclock.New(time.Now())
+	release := map[int]chan struct{}{1: make(chan struct{}), 2: make(chan struct{})}
+	cache, err := NewWithOptions[int, int](2,
+		types.WithTTL[int, int](10*time.Second),
+		types.WithClock[int, int](clock),
+		types.WithRefreshAhead(func(_ context.Context, key int) (int, error) {
+			<-release[key]
+			return key * 10, nil
+		}, 0.5),
+	)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Set(2, 2)
+	clock.Advance(6 * time.Second)
+	cache.Get(1)
+	cache.Get(2)
+
+	// the refreshes complete in the reverse order of the reads
+	for _, key := range []int{2, 1} {
+		close(release[key])
+		deadline := time.Now().Add(time.Second)
+		for value, _ := cache.Peek(key); value != key*10 && time.Now().Before(deadline); value, _ = cache.Peek(key) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the least recently read key is evicted, and the refreshes are not updates
+	cache.Set(3, 3)
+	assert.False(t, cache.Contains(1))
+	assert.True(t, cache.Contains(2))
+	assert.Equal(t, uint64(0), cache.Stats().Updates)
+}
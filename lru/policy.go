@@ -0,0 +1,53 @@
+package lru
+
+import "container/list"
+
+// policy is the LRU eviction policy: a hit moves the key to the front of the list,
+// and the key at the back, the least recently used, is evicted.
+type policy[K comparable] struct {
+	nodes map[K]*list.Element
+	ll    *list.List
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	return &policy[K]{
+		nodes: make(map[K]*list.Element),
+		ll:    list.New(),
+	}
+}
+
+func (p *policy[K]) Add(key K, _ int64) bool {
+	p.nodes[key] = p.ll.PushFront(key)
+	return false
+}
+
+func (p *policy[K]) Hit(key K) {
+	p.ll.MoveToFront(p.nodes[key])
+}
+
+func (p *policy[K]) Update(K, int64) {}
+
+func (p *policy[K]) Remove(key K) {
+	p.ll.Remove(p.nodes[key])
+	delete(p.nodes, key)
+}
+
+func (p *policy[K]) Evict(_ *K, evict func(key K)) {
+	key := p.ll.Back().Value.(K)
+	p.Remove(key)
+	evict(key)
+}
+
+func (p *policy[K]) Victim(K) (key K, ok bool) {
+	if back := p.ll.Back(); back != nil {
+		return back.Value.(K), true
+	}
+	return key, false
+}
+
+func (p *policy[K]) Resize(int64) {}
+
+func (p *policy[K]) Reset() {
+	p.ll.Init()
+	clear(p.nodes)
+}
@@ -0,0 +1,198 @@
+// Package metrics exposes the statistics of the caches in the Prometheus text exposition format,
+// without depending on the Prometheus client library.
+//
+//	handler := metrics.NewHandler()
+//	handler.Register("users", usersCache)
+//	http.Handle("/metrics", handler)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// contentType is the content type of the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Source is a cache whose metrics can be exposed, such as sieve.Sieve, s3fifo.S3FIFO or sharded.Cache.
+type Source interface {
+	// Stats returns the statistics of the cache.
+	Stats() types.Stats
+
+	// Len returns the number of entries in the cache.
+	Len() int
+
+	// Capacity returns the maximum number of entries in the cache,
+	// or the maximum total weight of the entries if a weigher is set.
+	Capacity() int64
+}
+
+// QueueSource is a cache made of several queues, such as s3fifo.S3FIFO or sharded.Cache.
+// The lengths of its queues are exposed along with the metrics of the cache.
+type QueueSource interface {
+	// QueueLens returns the number of entries in the small and main queues, and the number of keys in the ghost queue.
+	QueueLens() (small, main, ghost int)
+}
+
+// Handler is an [http.Handler] serving the metrics of the registered caches.
+// The caches are told apart by the "cache" label. The zero value is ready to use.
+type Handler struct {
+	mu     sync.RWMutex
+	caches map[string]Source
+}
+
+// NewHandler creates a handler with no cache registered.
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Register exposes the metrics of the cache under the given name, replacing the cache registered under it.
+func (h *Handler) Register(name string, cache Source) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.caches == nil {
+		h.caches = make(map[string]Source)
+	}
+	h.caches[name] = cache
+}
+
+// Unregister stops exposing the metrics of the cache registered under the given name.
+func (h *Handler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.caches, name)
+}
+
+// ServeHTTP writes the metrics of the registered caches.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	bw := bufio.NewWriter(w)
+	_, _ = h.WriteTo(bw)
+	_ = bw.Flush()
+}
+
+// sample is the state of a cache at the time it's exposed.
+type sample struct {
+	name     string
+	stats    types.Stats
+	len      int
+	capacity int64
+
+	queues bool
+	small  int
+	main   int
+	ghost  int
+}
+
+// WriteTo writes the metrics of the registered caches to w, sorted by cache name.
+func (h *Handler) WriteTo(w io.Writer) (n int64, err error) {
+	h.mu.RLock()
+	samples := make([]sample, 0, len(h.caches))
+	for name, cache := range h.caches {
+		s := sample{
+			name:     name,
+			stats:    cache.Stats(),
+			len:      cache.Len(),
+			capacity: cache.Capacity(),
+		}
+		if q, ok := cache.(QueueSource); ok {
+			s.queues = true
+			s.small, s.main, s.ghost = q.QueueLens()
+		}
+		samples = append(samples, s)
+	}
+	h.mu.RUnlock()
+
+	slices.SortFunc(samples, func(a, b sample) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	ew := &errWriter{w: w}
+	family(ew, "fifo_cache_hits_total", "counter", "Number of gets that found the key.")
+	for _, s := range samples {
+		metric(ew, "fifo_cache_hits_total", s.name, "", "", s.stats.Hits)
+	}
+
+	family(ew, "fifo_cache_misses_total", "counter", "Number of gets that did not find the key.")
+	for _, s := range samples {
+		metric(ew, "fifo_cache_misses_total", s.name, "", "", s.stats.Misses)
+	}
+
+	family(ew, "fifo_cache_evictions_total", "counter", "Number of entries removed from the cache, by reason.")
+	for _, s := range samples {
+		for reason, count := range s.stats.Evictions {
+			metric(ew, "fifo_cache_evictions_total", s.name, "reason", types.EvictReason(reason).String(), count)
+		}
+	}
+
+	family(ew, "fifo_cache_entries", "gauge", "Number of entries in the cache.")
+	for _, s := range samples {
+		metric(ew, "fifo_cache_entries", s.name, "", "", s.len)
+	}
+
+	family(ew, "fifo_cache_capacity", "gauge", "Maximum number of entries, or maximum total weight of the entries if a weigher is set.")
+	for _, s := range samples {
+		metric(ew, "fifo_cache_capacity", s.name, "", "", s.capacity)
+	}
+
+	if slices.ContainsFunc(samples, func(s sample) bool { return s.queues }) {
+		family(ew, "fifo_cache_queue_length", "gauge", "Number of entries in each queue of the cache.")
+		for _, s := range samples {
+			if !s.queues {
+				continue
+			}
+			metric(ew, "fifo_cache_queue_length", s.name, "queue", "small", s.small)
+			metric(ew, "fifo_cache_queue_length", s.name, "queue", "main", s.main)
+			metric(ew, "fifo_cache_queue_length", s.name, "queue", "ghost", s.ghost)
+		}
+	}
+
+	return ew.n, ew.err
+}
+
+// family writes the HELP and TYPE lines of a metric.
+func family(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// metric writes a sample of a metric for the given cache, with an optional extra label.
+func metric[T int | int64 | uint64](w io.Writer, name, cache, label, value string, v T) {
+	if label == "" {
+		fmt.Fprintf(w, "%s{cache=\"%s\"} %d\n", name, escape(cache), v)
+		return
+	}
+	fmt.Fprintf(w, "%s{cache=\"%s\",%s=\"%s\"} %d\n", name, escape(cache), label, escape(value), v)
+}
+
+// labelEscaper escapes a label value as required by the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// errWriter keeps the number of bytes written and the first error, and stops writing after it.
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/s3fifo"
+	"github.com/scalalang2/golang-fifo/sharded"
+	"github.com/scalalang2/golang-fifo/sieve"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+func scrape(t *testing.T, handler *Handler) string {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestHandler(t *testing.T) {
+	sieveCache := sieve.New[int, int](2, 0)
+	defer sieveCache.Close()
+	s3fifoCache := s3fifo.New[int, int](10, 0)
+	defer s3fifoCache.Close()
+
+	sieveCache.Set(1, 1)
+	sieveCache.Set(2, 2)
+	sieveCache.Set(3, 3)
+	sieveCache.Get(3)
+	sieveCache.Get(4)
+	sieveCache.Remove(3)
+
+	s3fifoCache.Set(1, 1)
+
+	handler := NewHandler()
+	handler.Register("users", sieveCache)
+	handler.Register("sessions", s3fifoCache)
+
+	body := scrape(t, handler)
+	for _, line := range []string{
+		"# TYPE fifo_cache_hits_total counter",
+		`fifo_cache_hits_total{cache="users"} 1`,
+		`fifo_cache_misses_total{cache="users"} 1`,
+		`fifo_cache_evictions_total{cache="users",reason="expired"} 0`,
+		`fifo_cache_evictions_total{cache="users",reason="evicted"} 1`,
+		`fifo_cache_evictions_total{cache="users",reason="removed"} 1`,
+		"# TYPE fifo_cache_entries gauge",
+		`fifo_cache_entries{cache="users"} 1`,
+		`fifo_cache_capacity{cache="users"} 2`,
+		`fifo_cache_entries{cache="sessions"} 1`,
+		`fifo_cache_capacity{cache="sessions"} 10`,
+		`fifo_cache_queue_length{cache="sessions",queue="small"} 1`,
+		`fifo_cache_queue_length{cache="sessions",queue="main"} 0`,
+		`fifo_cache_queue_length{cache="sessions",queue="ghost"} 0`,
+	} {
+		assert.True(t, strings.Contains(body, line+"\n"), line)
+	}
+	assert.False(t, strings.Contains(body, `fifo_cache_queue_length{cache="users"`))
+
+	// the caches are sorted by name
+	assert.True(t, strings.Index(body, `{cache="sessions"}`) < strings.Index(body, `{cache="users"}`))
+
+	handler.Unregister("users")
+	assert.False(t, strings.Contains(scrape(t, handler), `cache="users"`))
+}
+
+func TestShardedCache(t *testing.T) {
+	cache := sharded.New[int, int](10, 2, func(size int) types.Cache[int, int] {
+		return s3fifo.New[int, int](size, 0)
+	})
+	defer cache.Close()
+
+	cache.Set(1, 1)
+	cache.Get(1)
+
+	handler := NewHandler()
+	handler.Register("shards", cache)
+
+	body := scrape(t, handler)
+	for _, line := range []string{
+		`fifo_cache_hits_total{cache="shards"} 1`,
+		`fifo_cache_entries{cache="shards"} 1`,
+		`fifo_cache_capacity{cache="shards"} 10`,
+		`fifo_cache_queue_length{cache="shards",queue="small"} 1`,
+	} {
+		assert.True(t, strings.Contains(body, line+"\n"), line)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	cache := sieve.New[int, int](1, 0)
+	defer cache.Close()
+
+	var handler Handler
+	handler.Register("a\"b\\c\nd", cache)
+
+	var sb strings.Builder
+	_, err := handler.WriteTo(&sb)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(sb.String(), `fifo_cache_entries{cache="a\"b\\c\nd"} 0`))
+}
@@ -34,6 +34,16 @@ func (b *ghost[K]) add(key K) {
 	b.items[key] = e
 }
 
+// resize changes the size of the ghost, dropping the oldest keys if it shrinks.
+func (b *ghost[K]) resize(size int) {
+	b.size = size
+	for b.ll.Len() > b.size {
+		e := b.ll.Back()
+		delete(b.items, e.Value.(K))
+		b.ll.Remove(e)
+	}
+}
+
 func (b *ghost[K]) remove(key K) {
 	if e, ok := b.items[key]; ok {
 		b.ll.Remove(e)
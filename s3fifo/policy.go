@@ -0,0 +1,178 @@
+package s3fifo
+
+import "container/list"
+
+// node is a key in the small or main queue.
+type node[K comparable] struct {
+	key     K
+	freq    byte
+	weight  int64
+	inSmall bool // inSmall reports whether the key is in the small queue
+}
+
+// policy is the S3-FIFO eviction policy.
+//
+// New keys enter the small queue, taking about 10% of the cache, and the ones hit more than once
+// while in it move to the main queue when they reach its tail; the others are evicted and remembered
+// in the ghost queue. A key found in the ghost queue goes straight into the main queue, where
+// the keys are reinserted at its head until their frequency, decremented on each pass, drops to zero.
+type policy[K comparable] struct {
+	// followings are the fundamental data structures of S3FIFO algorithm.
+	nodes map[K]*list.Element
+	small *list.List
+	main  *list.List
+	ghost *ghost[K]
+
+	// capacity is the maximum total weight of the keys
+	capacity int64
+
+	// weight is the total weight of the keys,
+	// and smallWeight is the part of it held by the small queue.
+	weight      int64
+	smallWeight int64
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	return &policy[K]{
+		nodes: make(map[K]*list.Element),
+		small: list.New(),
+		main:  list.New(),
+		ghost: newGhost[K](0),
+	}
+}
+
+func (p *policy[K]) Add(key K, weight int64) (ghost bool) {
+	n := &node[K]{key: key, weight: weight}
+	if p.ghost.contains(key) {
+		p.ghost.remove(key)
+		p.nodes[key] = p.main.PushFront(n)
+		ghost = true
+	} else {
+		n.inSmall = true
+		p.nodes[key] = p.small.PushFront(n)
+	}
+	p.addWeight(n, weight)
+	return ghost
+}
+
+func (p *policy[K]) Hit(key K) {
+	n := p.nodes[key].Value.(*node[K])
+	n.freq = min(n.freq+1, 3)
+}
+
+func (p *policy[K]) Update(key K, weight int64) {
+	n := p.nodes[key].Value.(*node[K])
+	p.addWeight(n, weight-n.weight)
+	n.weight = weight
+}
+
+func (p *policy[K]) Remove(key K) {
+	el := p.nodes[key]
+	n := el.Value.(*node[K])
+	if n.inSmall {
+		p.small.Remove(el)
+	} else {
+		p.main.Remove(el)
+	}
+	p.addWeight(n, -n.weight)
+	delete(p.nodes, key)
+}
+
+func (p *policy[K]) Evict(_ *K, evict func(key K)) {
+	// if size of the small queue is greater than 10% of the total cache size.
+	// then, evict from the small queue
+	if p.main.Len() == 0 || p.smallWeight > p.capacity/10 {
+		p.evictFromSmall(evict)
+		return
+	}
+	p.evictFromMain(evict)
+}
+
+func (p *policy[K]) evictFromSmall(evict func(key K)) {
+	mainCacheSize := p.capacity / 10 * 9
+
+	evicted := false
+	for !evicted && p.small.Len() > 0 {
+		el := p.small.Back()
+		n := el.Value.(*node[K])
+
+		if n.freq > 1 {
+			// move the key from the small queue to the main queue
+			p.small.Remove(el)
+			p.smallWeight -= n.weight
+			n.inSmall = false
+			p.nodes[n.key] = p.main.PushFront(n)
+
+			if p.weight-p.smallWeight > mainCacheSize {
+				p.evictFromMain(evict)
+			}
+		} else {
+			p.Remove(n.key)
+			p.ghost.resize(p.ghostSize())
+			p.ghost.add(n.key)
+			evict(n.key)
+			evicted = true
+		}
+	}
+}
+
+func (p *policy[K]) evictFromMain(evict func(key K)) {
+	evicted := false
+	for !evicted && p.main.Len() > 0 {
+		el := p.main.Back()
+		n := el.Value.(*node[K])
+
+		if n.freq > 0 {
+			n.freq--
+			p.main.MoveToFront(el)
+		} else {
+			p.Remove(n.key)
+			evict(n.key)
+			evicted = true
+		}
+	}
+}
+
+// Victim returns the key at the tail of the queue the next eviction would start from.
+func (p *policy[K]) Victim(K) (key K, ok bool) {
+	queue := p.main
+	if p.main.Len() == 0 || p.smallWeight > p.capacity/10 {
+		queue = p.small
+	}
+	if queue.Len() == 0 {
+		return key, false
+	}
+	return queue.Back().Value.(*node[K]).key, true
+}
+
+// Resize sets the capacity, and shrinks the ghost queue if it holds more keys than the cache can.
+func (p *policy[K]) Resize(capacity int64) {
+	p.capacity = capacity
+	p.ghost.resize(p.ghostSize())
+}
+
+// ghostSize returns the number of keys the ghost queue holds: as many as the keys
+// of the average weight of the cache fit in its capacity, i.e. the capacity without a weigher.
+func (p *policy[K]) ghostSize() int {
+	if len(p.nodes) == 0 || p.weight <= int64(len(p.nodes)) {
+		return int(p.capacity)
+	}
+	return max(1, int(float64(p.capacity)*float64(len(p.nodes))/float64(p.weight)))
+}
+
+func (p *policy[K]) Reset() {
+	p.small.Init()
+	p.main.Init()
+	p.ghost.clear()
+	clear(p.nodes)
+	p.weight = 0
+	p.smallWeight = 0
+}
+
+// addWeight adds delta to the total weight and to the weight of the queue holding the key.
+func (p *policy[K]) addWeight(n *node[K], delta int64) {
+	p.weight += delta
+	if n.inSmall {
+		p.smallWeight += delta
+	}
+}
@@ -6,11 +6,26 @@ import (
 	"time"
 
 	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/fakeclock"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
 	"github.com/scalalang2/golang-fifo/types"
 )
 
 const noEvictionTTL = 0
 
+// advance moves the clock forward and advances the expiration wheel,
+// so that the expired entries are removed deterministically.
+func advance[K comparable, V any](cache *S3FIFO[K, V], clock *fakeclock.Clock, d time.Duration) {
+	clock.Advance(d)
+	cache.DeleteExpired()
+}
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
 func TestSetAndGet(t *testing.T) {
 	cache := New[string, string](10, noEvictionTTL)
 	cache.Set("hello", "world")
@@ -170,7 +185,9 @@ func TestClean(t *testing.T) {
 
 func TestTimeToLive(t *testing.T) {
 	ttl := time.Second
-	cache := New[int, int](10, ttl)
+	clock := fakeclock.New(time.Now())
+	cache, err := NewWithOptions[int, int](10, types.WithTTL[int, int](ttl), types.WithClock[int, int](clock))
+	assert.NoError(t, err)
 	numberOfEntries := 10
 
 	for num := 1; num <= numberOfEntries; num++ {
@@ -180,7 +197,7 @@ func TestTimeToLive(t *testing.T) {
 		assert.Equal(t, num, val)
 	}
 
-	time.Sleep(ttl * 2)
+	advance(cache, clock, ttl*2)
 
 	// check all entries are evicted
 	for num := 1; num <= numberOfEntries; num++ {
@@ -215,7 +232,9 @@ func TestEvictionCallback(t *testing.T) {
 
 func TestEvictionCallbackWithTTL(t *testing.T) {
 	var mu sync.Mutex
-	cache := New[int, int](10, time.Second)
+	clock := fakeclock.New(time.Now())
+	cache, err := NewWithOptions[int, int](10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	assert.NoError(t, err)
 	evicted := make(map[int]int)
 	cache.SetOnEvicted(func(key int, value int, _ types.EvictReason) {
 		mu.Lock()
@@ -228,21 +247,80 @@ func TestEvictionCallbackWithTTL(t *testing.T) {
 		cache.Set(i, i)
 	}
 
-	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	for {
-		select {
-		case <-timeout:
-			t.Fatal("timeout")
-		case <-ticker.C:
-			mu.Lock()
-			if len(evicted) == 10 {
-				for i := 1; i <= 10; i++ {
-					assert.Equal(t, i, evicted[i])
-				}
-				return
-			}
-			mu.Unlock()
-		}
+	advance(cache, clock, 2*time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 10, len(evicted))
+	for i := 1; i <= 10; i++ {
+		assert.Equal(t, i, evicted[i])
+	}
+}
+
+func TestStats(t *testing.T) {
+	cache := New[int, int](10, noEvictionTTL)
+	for i := 1; i <= 10; i++ {
+		cache.Set(i, i)
+	}
+	cache.Set(1, 10)
+
+	// the oldest entry is evicted to the ghost queue
+	cache.Set(11, 11)
+	cache.Get(11)
+	cache.Get(1)
+
+	// it goes straight into the main queue when it's set again
+	cache.Set(1, 1)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(12), stats.Sets)
+	assert.Equal(t, uint64(1), stats.Updates)
+	assert.Equal(t, uint64(2), stats.Evictions[types.EvictReasonEvicted])
+	assert.Equal(t, uint64(1), stats.GhostHits)
+
+	cache.ResetStats()
+	assert.Equal(t, types.Stats{}, cache.Stats())
+
+	cache.Close()
+}
+
+func TestResizeWithWeigher(t *testing.T) {
+	cache := New[int, string](10, noEvictionTTL)
+	cache.SetWeigher(func(_ int, value string) int64 {
+		return int64(len(value))
+	}, 10)
+
+	for i := 1; i <= 20; i++ {
+		cache.Set(i, "a")
+	}
+	_, _, ghost := cache.QueueLens()
+	assert.Equal(t, 10, ghost)
+
+	// the ghost queue shrinks along with the max weight
+	assert.Equal(t, 6, cache.Resize(4))
+	assert.Equal(t, int64(4), cache.Weight())
+	_, _, ghost = cache.QueueLens()
+	assert.Equal(t, 4, ghost)
+
+	cache.Close()
+}
+
+func TestGhostWithWeigher(t *testing.T) {
+	cache := New[int, string](10, noEvictionTTL)
+	cache.SetWeigher(func(_ int, _ string) int64 {
+		return 100
+	}, 1000)
+
+	for i := 0; i < 200; i++ {
+		cache.Set(i, "a")
 	}
+
+	// the ghost queue holds as many keys as the cache, not as many as its weight
+	small, main, ghost := cache.QueueLens()
+	assert.Equal(t, 10, small+main)
+	assert.Equal(t, 10, ghost)
+
+	cache.Close()
 }
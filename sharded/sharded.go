@@ -0,0 +1,161 @@
+// Package sharded provides a cache that spreads keys over independent shards,
+// so that operations on different shards don't contend for the same lock.
+package sharded
+
+import (
+	"hash/maphash"
+
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// Cache is a cache made of independent shards selected by the hash of the key.
+type Cache[K comparable, V any] struct {
+	seed   maphash.Seed
+	shards []types.Cache[K, V]
+}
+
+var _ types.Cache[int, int] = (*Cache[int, int])(nil)
+
+// New creates a cache holding up to size entries split across the given number of shards.
+// newShard is called for every shard with its share of the capacity, for example
+//
+//	sharded.New[string, string](size, 16, func(size int) types.Cache[string, string] {
+//		return sieve.New[string, string](size, ttl)
+//	})
+func New[K comparable, V any](size, shards int, newShard func(size int) types.Cache[K, V]) *Cache[K, V] {
+	if shards <= 0 {
+		panic("sharded: number of shards must be greater than 0")
+	}
+
+	if size < shards {
+		panic("sharded: size must be greater than or equal to the number of shards")
+	}
+
+	cache := &Cache[K, V]{
+		seed:   maphash.MakeSeed(),
+		shards: make([]types.Cache[K, V], shards),
+	}
+
+	for i := range cache.shards {
+		// the remainder of the capacity is spread over the first shards
+		shardSize := size / shards
+		if i < size%shards {
+			shardSize++
+		}
+		cache.shards[i] = newShard(shardSize)
+	}
+
+	return cache
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.shard(key).Set(key, value)
+}
+
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	return c.shard(key).Get(key)
+}
+
+func (c *Cache[K, V]) Remove(key K) (ok bool) {
+	return c.shard(key).Remove(key)
+}
+
+func (c *Cache[K, V]) Contains(key K) (ok bool) {
+	return c.shard(key).Contains(key)
+}
+
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	return c.shard(key).Peek(key)
+}
+
+func (c *Cache[K, V]) SetOnEvicted(callback types.OnEvictCallback[K, V]) {
+	for _, shard := range c.shards {
+		shard.SetOnEvicted(callback)
+	}
+}
+
+// Len returns the number of entries in the cache.
+// The shards are counted one after another, so the result is not a consistent snapshot
+// while the cache is being modified.
+func (c *Cache[K, V]) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Stats returns the sum of the statistics of the shards.
+// The shards without a Stats method are left out, and as for Len,
+// the shards are read one after another.
+func (c *Cache[K, V]) Stats() types.Stats {
+	var stats types.Stats
+	for _, shard := range c.shards {
+		s, ok := shard.(interface{ Stats() types.Stats })
+		if !ok {
+			continue
+		}
+
+		shardStats := s.Stats()
+		stats.Hits += shardStats.Hits
+		stats.Misses += shardStats.Misses
+		stats.Sets += shardStats.Sets
+		stats.Updates += shardStats.Updates
+		for reason := range stats.Evictions {
+			stats.Evictions[reason] += shardStats.Evictions[reason]
+		}
+		stats.GhostHits += shardStats.GhostHits
+		stats.NegativeHits += shardStats.NegativeHits
+		stats.NegativeSets += shardStats.NegativeSets
+		stats.Rejections += shardStats.Rejections
+	}
+	return stats
+}
+
+// Capacity returns the sum of the capacities of the shards, as for Stats,
+// leaving out the shards without a Capacity method.
+func (c *Cache[K, V]) Capacity() int64 {
+	var capacity int64
+	for _, shard := range c.shards {
+		if s, ok := shard.(interface{ Capacity() int64 }); ok {
+			capacity += s.Capacity()
+		}
+	}
+	return capacity
+}
+
+// queueSource is a shard made of several queues.
+type queueSource interface {
+	QueueLens() (small, main, ghost int)
+}
+
+// QueueLens returns the sum of the lengths of the queues of the shards, such as s3fifo.S3FIFO,
+// leaving out the shards without a QueueLens method.
+func (c *Cache[K, V]) QueueLens() (small, main, ghost int) {
+	for _, shard := range c.shards {
+		if s, ok := shard.(queueSource); ok {
+			shardSmall, shardMain, shardGhost := s.QueueLens()
+			small += shardSmall
+			main += shardMain
+			ghost += shardGhost
+		}
+	}
+	return small, main, ghost
+}
+
+func (c *Cache[K, V]) Purge() {
+	for _, shard := range c.shards {
+		shard.Purge()
+	}
+}
+
+func (c *Cache[K, V]) Close() {
+	for _, shard := range c.shards {
+		shard.Close()
+	}
+}
+
+// shard returns the shard holding the given key.
+func (c *Cache[K, V]) shard(key K) types.Cache[K, V] {
+	return c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
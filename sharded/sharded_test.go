@@ -0,0 +1,146 @@
+package sharded
+
+import (
+	"sync"
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/s3fifo"
+	"github.com/scalalang2/golang-fifo/sieve"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+func newSieve(size int) types.Cache[int, int] {
+	return sieve.New[int, int](size, 0)
+}
+
+func newS3FIFO(size int) types.Cache[int, int] {
+	return s3fifo.New[int, int](size, 0)
+}
+
+func TestGetAndSet(t *testing.T) {
+	for _, newShard := range []func(int) types.Cache[int, int]{newSieve, newS3FIFO} {
+		cache := New[int, int](100, 4, newShard)
+		for i := 0; i < 50; i++ {
+			cache.Set(i, i*10)
+		}
+
+		for i := 0; i < 50; i++ {
+			val, ok := cache.Get(i)
+			assert.True(t, ok)
+			assert.Equal(t, i*10, val)
+
+			val, ok = cache.Peek(i)
+			assert.True(t, ok)
+			assert.Equal(t, i*10, val)
+			assert.True(t, cache.Contains(i))
+		}
+		assert.Equal(t, 50, cache.Len())
+
+		assert.True(t, cache.Remove(0))
+		assert.False(t, cache.Remove(0))
+		assert.False(t, cache.Contains(0))
+		assert.Equal(t, 49, cache.Len())
+
+		cache.Purge()
+		assert.Equal(t, 0, cache.Len())
+
+		cache.Close()
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	sizes := make([]int, 0)
+	cache := New[int, int](10, 4, func(size int) types.Cache[int, int] {
+		sizes = append(sizes, size)
+		return newSieve(size)
+	})
+	assert.Equal(t, []int{3, 3, 2, 2}, sizes)
+
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+	assert.True(t, cache.Len() <= 10)
+
+	cache.Close()
+}
+
+func TestStats(t *testing.T) {
+	for _, newShard := range []func(int) types.Cache[int, int]{newSieve, newS3FIFO} {
+		cache := New[int, int](100, 4, newShard)
+		for i := 0; i < 50; i++ {
+			cache.Set(i, i)
+		}
+		cache.Set(0, 0)
+		for i := 0; i < 100; i++ {
+			cache.Get(i)
+		}
+		cache.Remove(1)
+
+		// the statistics of the shards are summed
+		stats := cache.Stats()
+		assert.Equal(t, uint64(50), stats.Hits)
+		assert.Equal(t, uint64(50), stats.Misses)
+		assert.Equal(t, uint64(50), stats.Sets)
+		assert.Equal(t, uint64(1), stats.Updates)
+		assert.Equal(t, uint64(1), stats.Evicted(types.EvictReasonRemoved))
+		assert.Equal(t, 0.5, stats.HitRatio())
+
+		cache.Close()
+	}
+}
+
+func TestCapacityAndQueueLens(t *testing.T) {
+	cache := New[int, int](100, 4, newS3FIFO)
+	defer cache.Close()
+
+	for i := 0; i < 200; i++ {
+		cache.Set(i, i)
+	}
+
+	// the capacities and the queue lengths of the shards are summed
+	assert.Equal(t, int64(100), cache.Capacity())
+	small, main, ghost := cache.QueueLens()
+	assert.Equal(t, cache.Len(), small+main)
+	assert.True(t, ghost > 0)
+}
+
+func TestEvictionCallback(t *testing.T) {
+	var mu sync.Mutex
+	evicted := make(map[int]int)
+	cache := New[int, int](4, 4, newSieve)
+	cache.SetOnEvicted(func(key int, value int, _ types.EvictReason) {
+		mu.Lock()
+		evicted[key] = value
+		mu.Unlock()
+	})
+
+	for i := 0; i < 100; i++ {
+		cache.Set(i, i)
+	}
+
+	// every shard calls the callback
+	assert.Equal(t, 100-cache.Len(), len(evicted))
+
+	cache.Close()
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	cache := New[int, int](1000, 8, newSieve)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Set(g*1000+i, i)
+				cache.Get(g*1000 + i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.True(t, cache.Len() <= 1000)
+	cache.Close()
+}
@@ -0,0 +1,122 @@
+package sieve
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+// node is a key in the queue.
+type node[K comparable] struct {
+	key     K
+	visited atomic.Bool // visited is set without the write lock on cache hit
+	element *list.Element
+}
+
+// policy is the SIEVE eviction policy.
+//
+// The keys sit in a FIFO queue, and a hit only sets the visited bit of the key,
+// so that hits don't need the write lock. To evict a key, the hand moves from the tail
+// towards the head of the queue, clearing the visited bits it passes, and evicts
+// the first key whose bit is already clear. The hand stays there for the next eviction.
+type policy[K comparable] struct {
+	nodes map[K]*node[K]
+	ll    *list.List
+	hand  *list.Element
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	return &policy[K]{
+		nodes: make(map[K]*node[K]),
+		ll:    list.New(),
+	}
+}
+
+func (p *policy[K]) Add(key K, _ int64) bool {
+	n := &node[K]{key: key}
+	n.element = p.ll.PushFront(n)
+	p.nodes[key] = n
+	return false
+}
+
+func (p *policy[K]) Hit(key K) {
+	p.Visit(key)
+}
+
+func (p *policy[K]) Visit(key K) {
+	// skip the store if the bit is already set, to avoid contention on the cache line
+	if n := p.nodes[key]; !n.visited.Load() {
+		n.visited.Store(true)
+	}
+}
+
+func (p *policy[K]) Update(K, int64) {}
+
+func (p *policy[K]) Remove(key K) {
+	n := p.nodes[key]
+
+	// if the element to be removed is the hand,
+	// then move the hand to the previous one.
+	if n.element == p.hand {
+		p.hand = p.hand.Prev()
+	}
+
+	p.ll.Remove(n.element)
+	delete(p.nodes, key)
+}
+
+func (p *policy[K]) Evict(_ *K, evict func(key K)) {
+	o := p.hand
+	// if o is nil, then assign it to the tail element in the list
+	if o == nil {
+		o = p.ll.Back()
+	}
+
+	for n := o.Value.(*node[K]); n.visited.Load(); n = o.Value.(*node[K]) {
+		n.visited.Store(false)
+		o = o.Prev()
+		if o == nil {
+			o = p.ll.Back()
+		}
+	}
+
+	p.hand = o.Prev()
+	key := o.Value.(*node[K]).key
+	p.ll.Remove(o)
+	delete(p.nodes, key)
+	evict(key)
+}
+
+// Victim returns the key the next eviction would remove, without clearing the visited bits.
+func (p *policy[K]) Victim(K) (key K, ok bool) {
+	start := p.hand
+	if start == nil {
+		start = p.ll.Back()
+	}
+	if start == nil {
+		return key, false
+	}
+
+	for o := start; ; {
+		if n := o.Value.(*node[K]); !n.visited.Load() {
+			return n.key, true
+		}
+
+		o = o.Prev()
+		if o == nil {
+			o = p.ll.Back()
+		}
+		if o == start {
+			// every key is visited, the eviction clears them all and comes back to the start
+			return start.Value.(*node[K]).key, true
+		}
+	}
+}
+
+func (p *policy[K]) Resize(int64) {}
+
+func (p *policy[K]) Reset() {
+	// hand pointer must also be reset
+	p.hand = nil
+	p.ll.Init()
+	clear(p.nodes)
+}
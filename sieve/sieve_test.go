@@ -6,11 +6,26 @@ import (
 	"time"
 
 	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/fakeclock"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
 	"github.com/scalalang2/golang-fifo/types"
 )
 
 const noEvictionTTL = 0
 
+// advance moves the clock forward and advances the expiration wheel,
+// so that the expired entries are removed deterministically.
+func advance[K comparable, V any](cache *Sieve[K, V], clock *fakeclock.Clock, d time.Duration) {
+	clock.Advance(d)
+	cache.DeleteExpired()
+}
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
 func TestGetAndSet(t *testing.T) {
 	items := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
 	cache := New[int, int](10, noEvictionTTL)
@@ -124,7 +139,9 @@ func TestPurge(t *testing.T) {
 
 func TestTimeToLive(t *testing.T) {
 	ttl := time.Second
-	cache := New[int, int](10, ttl)
+	clock := fakeclock.New(time.Now())
+	cache, err := NewWithOptions[int, int](10, types.WithTTL[int, int](ttl), types.WithClock[int, int](clock))
+	assert.NoError(t, err)
 	numberOfEntries := 10
 
 	for num := 1; num <= numberOfEntries; num++ {
@@ -134,7 +151,7 @@ func TestTimeToLive(t *testing.T) {
 		assert.Equal(t, num, val)
 	}
 
-	time.Sleep(ttl * 2)
+	advance(cache, clock, ttl*2)
 
 	// check all entries are evicted
 	for num := 1; num <= numberOfEntries; num++ {
@@ -169,7 +186,9 @@ func TestEvictionCallback(t *testing.T) {
 
 func TestEvictionCallbackWithTTL(t *testing.T) {
 	var mu sync.Mutex
-	cache := New[int, int](10, time.Second)
+	clock := fakeclock.New(time.Now())
+	cache, err := NewWithOptions[int, int](10, types.WithTTL[int, int](time.Second), types.WithClock[int, int](clock))
+	assert.NoError(t, err)
 	evicted := make(map[int]int)
 	cache.SetOnEvicted(func(key int, value int, _ types.EvictReason) {
 		mu.Lock()
@@ -182,22 +201,13 @@ func TestEvictionCallbackWithTTL(t *testing.T) {
 		cache.Set(i, i)
 	}
 
-	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	for {
-		select {
-		case <-timeout:
-			t.Fatal("timeout")
-		case <-ticker.C:
-			mu.Lock()
-			if len(evicted) == 10 {
-				for i := 1; i <= 10; i++ {
-					assert.Equal(t, i, evicted[i])
-				}
-				return
-			}
-			mu.Unlock()
-		}
+	advance(cache, clock, 2*time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 10, len(evicted))
+	for i := 1; i <= 10; i++ {
+		assert.Equal(t, i, evicted[i])
 	}
 }
 
@@ -206,16 +216,54 @@ func TestLargerWorkloadsThanCacheSize(t *testing.T) {
 		bytes []byte
 	}
 
-	cache := New[int32, value](512, time.Millisecond)
+	// the entries expire while the workload runs, as the clock moves forward
+	clock := fakeclock.New(time.Now())
+	cache, err := NewWithOptions[int32, value](512, types.WithTTL[int32, value](time.Millisecond), types.WithClock[int32, value](clock))
+	assert.NoError(t, err)
 	workload := int32(10240)
 	for i := int32(0); i < workload; i++ {
 		val := value{
 			bytes: make([]byte, 10),
 		}
 		cache.Set(i, val)
+		clock.Advance(time.Microsecond)
 
 		v, ok := cache.Get(i)
 		assert.True(t, ok)
 		assert.Equal(t, v, val)
 	}
 }
+
+func TestAllDoesNotUpdateVisited(t *testing.T) {
+	cache := New[int, int](3, noEvictionTTL)
+	for i := 1; i <= 3; i++ {
+		cache.Set(i, i)
+	}
+
+	for range cache.All() {
+	}
+
+	// the oldest entry is evicted as if it had never been accessed
+	cache.Set(4, 4)
+	assert.False(t, cache.Contains(1))
+
+	cache.Close()
+}
+
+func TestComputeSetsVisited(t *testing.T) {
+	cache := New[int, int](3, noEvictionTTL)
+	for i := 1; i <= 3; i++ {
+		cache.Set(i, i)
+	}
+
+	cache.Compute(1, func(old int, ok bool) (int, types.ComputeOp) {
+		return old, types.ComputeKeep
+	})
+
+	// the computed entry is visited, so the next one is evicted
+	cache.Set(4, 4)
+	assert.True(t, cache.Contains(1))
+	assert.False(t, cache.Contains(2))
+
+	cache.Close()
+}
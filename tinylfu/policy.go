@@ -0,0 +1,188 @@
+package tinylfu
+
+import (
+	"container/list"
+
+	"github.com/scalalang2/golang-fifo/admission"
+)
+
+// maxSketchSize bounds the size the sketch is created for, as a capacity counted in weight,
+// such as bytes, may be much larger than the number of keys.
+const maxSketchSize = 1 << 20
+
+// queue is the queue holding a key.
+type queue uint8
+
+const (
+	window queue = iota
+	probation
+	protected
+)
+
+// node is a key in one of the queues.
+type node[K comparable] struct {
+	key    K
+	weight int64
+	queue  queue
+}
+
+// policy is the W-TinyLFU eviction policy.
+//
+// New keys enter a small LRU window. The key falling out of the window is a candidate
+// for the main cache, a segmented LRU made of a probation and a protected segment.
+// When the cache is full, the candidate only gets in if the TinyLFU sketch estimates
+// it's accessed more often than the victim at the tail of the probation segment;
+// otherwise the candidate is evicted. A hit in the probation segment promotes the key
+// to the protected segment, whose tail is demoted back to probation when it's full.
+//
+// The sizes of the window and the segments are counted in weight.
+type policy[K comparable] struct {
+	sketch     *admission.TinyLFU[K]
+	sketchSize int
+
+	nodes   map[K]*list.Element
+	queues  [3]*list.List
+	weights [3]int64
+
+	windowSize    int64
+	mainSize      int64
+	protectedSize int64
+}
+
+func newPolicy[K comparable]() *policy[K] {
+	p := &policy[K]{
+		nodes: make(map[K]*list.Element),
+	}
+	for i := range p.queues {
+		p.queues[i] = list.New()
+	}
+	return p
+}
+
+func (p *policy[K]) Add(key K, weight int64) bool {
+	p.sketch.Record(key)
+	p.push(key, weight, window)
+
+	// the keys falling out of the window go to probation while the main cache has room for them,
+	// otherwise they stay in the window until Evict makes them compete with the victims
+	for p.weights[window] > p.windowSize && p.queues[window].Len() > 1 {
+		n := p.queues[window].Back().Value.(*node[K])
+		if p.weights[probation]+p.weights[protected]+n.weight > p.mainSize {
+			break
+		}
+		p.Remove(n.key)
+		p.push(n.key, n.weight, probation)
+	}
+	return false
+}
+
+func (p *policy[K]) Hit(key K) {
+	p.sketch.Record(key)
+
+	el := p.nodes[key]
+	n := el.Value.(*node[K])
+	switch n.queue {
+	case window, protected:
+		p.queues[n.queue].MoveToFront(el)
+	case probation:
+		p.Remove(key)
+		p.push(key, n.weight, protected)
+		for p.weights[protected] > p.protectedSize && p.queues[protected].Len() > 1 {
+			demoted := p.queues[protected].Back().Value.(*node[K])
+			p.Remove(demoted.key)
+			p.push(demoted.key, demoted.weight, probation)
+		}
+	}
+}
+
+func (p *policy[K]) Update(key K, weight int64) {
+	n := p.nodes[key].Value.(*node[K])
+	p.weights[n.queue] += weight - n.weight
+	n.weight = weight
+}
+
+func (p *policy[K]) Remove(key K) {
+	el := p.nodes[key]
+	n := el.Value.(*node[K])
+	p.queues[n.queue].Remove(el)
+	p.weights[n.queue] -= n.weight
+	delete(p.nodes, key)
+}
+
+func (p *policy[K]) Evict(_ *K, evict func(key K)) {
+	victim, ok := p.victim()
+
+	// the window is full, so its tail is about to fall out of it:
+	// it competes with the probation tail for a place in the main cache
+	if p.weights[window] >= p.windowSize || !ok {
+		candidate := p.queues[window].Back().Value.(*node[K])
+
+		// unlike the admission policy of the other caches, a tie rejects the candidate,
+		// as the victim already proved itself in the main cache
+		if !ok || p.sketch.Estimate(candidate.key) <= p.sketch.Estimate(victim) {
+			p.Remove(candidate.key)
+			evict(candidate.key)
+			return
+		}
+
+		p.Remove(candidate.key)
+		p.push(candidate.key, candidate.weight, probation)
+	}
+
+	p.Remove(victim)
+	evict(victim)
+}
+
+// Victim returns the key the next eviction would remove.
+func (p *policy[K]) Victim(K) (key K, ok bool) {
+	victim, ok := p.victim()
+	if p.weights[window] < p.windowSize && ok {
+		return victim, true
+	}
+
+	candidate := p.queues[window].Back()
+	if candidate == nil {
+		return key, false
+	}
+	if !ok || p.sketch.Estimate(candidate.Value.(*node[K]).key) <= p.sketch.Estimate(victim) {
+		return candidate.Value.(*node[K]).key, true
+	}
+	return victim, true
+}
+
+// victim returns the key at the tail of the probation segment, or of the protected one if it's empty.
+func (p *policy[K]) victim() (key K, ok bool) {
+	for _, q := range []queue{probation, protected} {
+		if back := p.queues[q].Back(); back != nil {
+			return back.Value.(*node[K]).key, true
+		}
+	}
+	return key, false
+}
+
+func (p *policy[K]) Resize(capacity int64) {
+	// the window takes 1% of the cache, and the protected segment 80% of the main cache
+	p.windowSize = max(capacity/100, 1)
+	p.mainSize = capacity - p.windowSize
+	p.protectedSize = p.mainSize * 8 / 10
+
+	// the frequencies are forgotten only if the sketch no longer fits the cache
+	if size := int(min(capacity, maxSketchSize)); size != p.sketchSize {
+		p.sketch = admission.NewTinyLFU[K](size)
+		p.sketchSize = size
+	}
+}
+
+func (p *policy[K]) Reset() {
+	for _, q := range p.queues {
+		q.Init()
+	}
+	p.weights = [3]int64{}
+	clear(p.nodes)
+}
+
+// push adds the key at the front of the queue.
+func (p *policy[K]) push(key K, weight int64, q queue) {
+	p.nodes[key] = p.queues[q].PushFront(&node[K]{key: key, weight: weight, queue: q})
+	p.weights[q] += weight
+}
@@ -0,0 +1,43 @@
+// Package tinylfu implements the W-TinyLFU cache: a small LRU window admitting new keys,
+// in front of a segmented LRU main cache guarded by a TinyLFU admission policy.
+//
+// The window absorbs the bursts of new keys, while the frequency sketch keeps the keys
+// accessed only once from replacing the popular ones, which makes W-TinyLFU resistant to scans.
+// See [TinyLFU: A Highly Efficient Cache Admission Policy] for the details.
+//
+// [TinyLFU: A Highly Efficient Cache Admission Policy]: https://arxiv.org/abs/1512.00727
+package tinylfu
+
+import (
+	"time"
+
+	"github.com/scalalang2/golang-fifo/internal/cache"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+// TinyLFU is a W-TinyLFU cache. Peek, Contains and iterating over the cache don't count as accesses.
+type TinyLFU[K comparable, V any] struct {
+	*cache.Cache[K, V]
+}
+
+var (
+	_ types.StaleLoadingCache[int, int] = (*TinyLFU[int, int])(nil)
+	_ types.BatchCache[int, int]        = (*TinyLFU[int, int])(nil)
+	_ types.AtomicCache[int, int]       = (*TinyLFU[int, int])(nil)
+)
+
+// New creates a cache holding up to size entries, with the given default TTL.
+// Zero ttl means no expiration. It panics if size is not positive.
+func New[K comparable, V any](size int, ttl time.Duration) *TinyLFU[K, V] {
+	return &TinyLFU[K, V]{cache.Must(cache.New("tinylfu", size, newPolicy[K](), types.WithTTL[K, V](ttl)))}
+}
+
+// NewWithOptions creates a cache holding up to size entries, configured with the given options.
+// It returns an error if the options are invalid.
+func NewWithOptions[K comparable, V any](size int, opts ...types.Option[K, V]) (*TinyLFU[K, V], error) {
+	c, err := cache.New("tinylfu", size, newPolicy[K](), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TinyLFU[K, V]{c}, nil
+}
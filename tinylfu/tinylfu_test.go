@@ -0,0 +1,86 @@
+package tinylfu
+
+import (
+	"testing"
+
+	"fortio.org/assert"
+	"github.com/scalalang2/golang-fifo/internal/cachetest"
+	"github.com/scalalang2/golang-fifo/types"
+)
+
+const noEvictionTTL = 0
+
+func TestCache(t *testing.T) {
+	cachetest.Run(t, func(size int, opts ...types.Option[int, int]) (cachetest.Cache, error) {
+		return NewWithOptions[int, int](size, opts...)
+	})
+}
+
+func TestTinyLFUPolicy(t *testing.T) {
+	cache := New[int, int](100, noEvictionTTL)
+	defer cache.Close()
+
+	// the popular keys are accessed many times
+	for i := 0; i < 50; i++ {
+		cache.Set(i, i)
+	}
+	cache.Set(-1, -1) // pushes the last popular key out of the window
+	for j := 0; j < 5; j++ {
+		for i := 0; i < 50; i++ {
+			cache.Get(i)
+		}
+	}
+
+	// a scan of keys accessed once doesn't flush them
+	for i := 1000; i < 2000; i++ {
+		cache.Set(i, i)
+	}
+	for i := 0; i < 50; i++ {
+		assert.True(t, cache.Contains(i))
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := newPolicy[int]()
+	p.Resize(10)
+	var evicted []int
+	evict := func(key int) {
+		evicted = append(evicted, key)
+	}
+	add := func(key int) {
+		if len(p.nodes) == 10 {
+			p.Evict(&key, evict)
+		}
+		p.Add(key, 1)
+	}
+
+	// the window holds a single key, the other ones go to probation
+	for i := 1; i <= 10; i++ {
+		add(i)
+	}
+	assert.Equal(t, 1, p.queues[window].Len())
+	assert.Equal(t, 9, p.queues[probation].Len())
+
+	// a hit in probation promotes the key
+	p.Hit(1)
+	assert.Equal(t, protected, p.nodes[1].Value.(*node[int]).queue)
+
+	// the protected segment is bounded, its tail is demoted back to probation
+	for i := 2; i <= 9; i++ {
+		p.Hit(i)
+	}
+	assert.Equal(t, 7, p.queues[protected].Len())
+	assert.Equal(t, probation, p.nodes[1].Value.(*node[int]).queue)
+
+	// a candidate accessed less often than the victim is evicted
+	for i := 0; i < 3; i++ {
+		p.Hit(1)
+	}
+	p.Remove(2)
+	p.Hit(10)
+	assert.Equal(t, 0, len(evicted))
+	add(11)
+	assert.Equal(t, 0, len(evicted))
+	add(12)
+	assert.Equal(t, []int{11}, evicted)
+}
@@ -0,0 +1,12 @@
+package types
+
+// Admission decides whether a new key is worth the place of the entry that would be evicted for it.
+// It's consulted by the caches when a new key is set while they are full.
+// Its methods may be called concurrently.
+type Admission[K comparable] interface {
+	// Record records an access to the key, whether it's found in the cache or not.
+	Record(key K)
+
+	// Admit reports whether the candidate key should be set in place of the victim key.
+	Admit(candidate, victim K) bool
+}
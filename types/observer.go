@@ -0,0 +1,44 @@
+package types
+
+import "time"
+
+// Observer is notified of the events of a cache, to feed metrics or traces
+// without the cache depending on a telemetry library.
+//
+// Its methods are called synchronously, possibly with the cache lock held,
+// so they must be fast and must not call back into the cache, which may deadlock.
+// They are called concurrently, for example by parallel Gets only holding a read lock,
+// so implementations must be safe for concurrent use.
+type Observer interface {
+	// OnGet is called for every lookup of a key, with hit reporting whether the key was found.
+	OnGet(hit bool)
+
+	// OnSet is called for every value set, with update reporting whether it replaced the value of an existing entry.
+	OnSet(update bool)
+
+	// OnEvict is called for every entry removed from the cache.
+	OnEvict(reason EvictReason)
+
+	// OnExpireSweep is called after the expired entries are swept,
+	// with the number of entries removed and the time the sweep took.
+	OnExpireSweep(count int, duration time.Duration)
+
+	// OnLockWait is called with the time waited to acquire the cache lock.
+	OnLockWait(wait time.Duration)
+}
+
+// NoopObserver is an observer ignoring every event. It's the default observer of the caches,
+// and can be embedded to implement only some methods of [Observer].
+type NoopObserver struct{}
+
+var _ Observer = NoopObserver{}
+
+func (NoopObserver) OnGet(bool) {}
+
+func (NoopObserver) OnSet(bool) {}
+
+func (NoopObserver) OnEvict(EvictReason) {}
+
+func (NoopObserver) OnExpireSweep(int, time.Duration) {}
+
+func (NoopObserver) OnLockWait(time.Duration) {}
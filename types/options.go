@@ -0,0 +1,241 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidSize is returned when the size of a cache is not positive.
+	ErrInvalidSize = errors.New("size must be greater than 0")
+	// ErrInvalidMaxWeight is returned when a weigher is set with a max weight that is not positive.
+	ErrInvalidMaxWeight = errors.New("max weight must be greater than 0")
+	// ErrInvalidCleanupInterval is returned when the cleanup interval is negative.
+	ErrInvalidCleanupInterval = errors.New("cleanup interval must not be negative")
+	// ErrInvalidAsyncEviction is returned when the number of eviction workers or their buffer is negative.
+	ErrInvalidAsyncEviction = errors.New("eviction workers and buffer must not be negative")
+	// ErrInvalidRefreshAhead is returned when the refresh-ahead fraction is not in (0, 1].
+	ErrInvalidRefreshAhead = errors.New("refresh-ahead fraction must be in (0, 1]")
+	// ErrInvalidNegativeCaching is returned when negative caching is set with a size or a not-found TTL
+	// that is not positive, or a negative error TTL.
+	ErrInvalidNegativeCaching = errors.New("negative caching size and not-found TTL must be greater than 0, and error TTL must not be negative")
+)
+
+// Options holds the configuration shared by the cache implementations.
+type Options[K comparable, V any] struct {
+	// TTL is the default time to live of the entries. Zero means no expiration.
+	TTL time.Duration
+
+	// OnEvicted is called when an entry is evicted from the cache.
+	OnEvicted OnEvictCallback[K, V]
+
+	// OnCallbackPanic is called when OnEvicted panics. Nil means the panic is recovered and ignored.
+	OnCallbackPanic PanicHandler[K, V]
+
+	// Weigher returns the weight of an entry. If set, the cache is bounded
+	// by MaxWeight instead of the number of entries.
+	Weigher Weigher[K, V]
+
+	// MaxWeight is the maximum total weight of the entries when Weigher is set.
+	MaxWeight int64
+
+	// CleanupInterval is the interval between two expiration sweeps.
+	// Zero means the interval is derived from TTL.
+	CleanupInterval time.Duration
+
+	// Clock provides the current time to compute the expiration of the entries.
+	// It defaults to [SystemClock].
+	Clock Clock
+
+	// EvictionWorkers is the number of goroutines calling OnEvicted.
+	// Zero means OnEvicted is called by the goroutine that evicted the entry, once the cache lock is released
+	// and before the method that evicted it returns. The evictions of a key by different goroutines
+	// may then be reported out of order; the workers keep their order.
+	EvictionWorkers int
+
+	// EvictionBuffer is the number of evictions each eviction worker can buffer.
+	// The evictions beyond it are queued until a worker is ready for them,
+	// so the goroutine evicting entries never waits for the workers.
+	EvictionBuffer int
+
+	// RefreshLoader reloads the entries read within RefreshAhead of their expiration.
+	// Nil means the entries are never refreshed.
+	RefreshLoader Loader[K, V]
+
+	// RefreshAhead is the fraction of the TTL of an entry, before its expiration,
+	// in which a read triggers a background reload with RefreshLoader.
+	RefreshAhead float64
+
+	// StaleGrace is the time an expired entry is retained, to be served as stale
+	// by a loading get when its reload fails. Zero means expired entries are removed right away.
+	// The retained entries still count toward the capacity of the cache.
+	StaleGrace time.Duration
+
+	// NegativeSize is the maximum number of failed loads cached apart from the entries.
+	// Zero means the failed loads are not cached.
+	NegativeSize int
+
+	// NotFoundTTL is the time a load failing with [ErrNotFound] is cached.
+	NotFoundTTL time.Duration
+
+	// ErrorTTL is the time a load failing with any other error is cached.
+	// Zero means the other errors are not cached.
+	ErrorTTL time.Duration
+
+	// Observer is notified of the events of the cache. It defaults to [NoopObserver].
+	Observer Observer
+
+	// Admission decides whether a new key is set when the cache is full. Nil means every key is set.
+	Admission Admission[K]
+}
+
+// Option configures a cache.
+type Option[K comparable, V any] func(*Options[K, V])
+
+// WithTTL sets the default time to live of the entries.
+// Zero or negative ttl means no expiration.
+func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.TTL = max(ttl, 0)
+	}
+}
+
+// WithOnEvicted sets the callback called when an entry is evicted from the cache.
+func WithOnEvicted[K comparable, V any](callback OnEvictCallback[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.OnEvicted = callback
+	}
+}
+
+// WithOnCallbackPanic sets the handler called with the value recovered from a panic of the eviction callback,
+// such as to log it. The handler is called by the goroutine calling the eviction callback,
+// once the cache lock is released, and must not panic itself.
+func WithOnCallbackPanic[K comparable, V any](handler PanicHandler[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.OnCallbackPanic = handler
+	}
+}
+
+// WithWeigher bounds the cache by the total weight of its entries instead of the number of entries.
+func WithWeigher[K comparable, V any](weigher Weigher[K, V], maxWeight int64) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Weigher = weigher
+		o.MaxWeight = maxWeight
+	}
+}
+
+// WithCleanupInterval sets the interval between two expiration sweeps.
+func WithCleanupInterval[K comparable, V any](interval time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.CleanupInterval = interval
+	}
+}
+
+// WithClock sets the clock used to compute the expiration of the entries.
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Clock = clock
+	}
+}
+
+// WithAsyncEviction calls the eviction callback from the given number of workers,
+// each buffering up to buffer evictions. The evictions of the same key are handled in order.
+func WithAsyncEviction[K comparable, V any](workers, buffer int) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.EvictionWorkers = workers
+		o.EvictionBuffer = buffer
+	}
+}
+
+// WithRefreshAhead reloads an entry in the background with the loader when it's read
+// within the given fraction of its TTL before it expires, e.g. 0.2 for the last 20% of its TTL.
+// The current value is served until the reload succeeds, which resets the TTL of the entry.
+func WithRefreshAhead[K comparable, V any](loader Loader[K, V], fraction float64) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.RefreshLoader = loader
+		o.RefreshAhead = fraction
+	}
+}
+
+// WithStaleWhileError retains the expired entries for the given grace period,
+// so that a loading get can serve them as stale when their reload fails.
+// Zero or negative grace means expired entries are removed right away.
+func WithStaleWhileError[K comparable, V any](grace time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.StaleGrace = max(grace, 0)
+	}
+}
+
+// WithNegativeCaching caches up to size failed loads apart from the entries,
+// the ones failing with [ErrNotFound] for notFoundTTL and the other ones for errorTTL,
+// so that a loading get returns their error without calling the loader again.
+// Zero errorTTL means only the keys not found are cached.
+func WithNegativeCaching[K comparable, V any](size int, notFoundTTL, errorTTL time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.NegativeSize = size
+		o.NotFoundTTL = notFoundTTL
+		o.ErrorTTL = errorTTL
+	}
+}
+
+// WithObserver sets the observer notified of the events of the cache.
+func WithObserver[K comparable, V any](observer Observer) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Observer = observer
+	}
+}
+
+// WithAdmission sets the admission policy deciding whether a new key is worth the place
+// of the entry that would be evicted for it, such as [admission.TinyLFU].
+//
+// [admission.TinyLFU]: https://pkg.go.dev/github.com/scalalang2/golang-fifo/admission#TinyLFU
+func WithAdmission[K comparable, V any](admission Admission[K]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Admission = admission
+	}
+}
+
+// NewOptions applies the given options and validates the result.
+func NewOptions[K comparable, V any](size int, opts ...Option[K, V]) (Options[K, V], error) {
+	o := Options[K, V]{
+		Clock:    SystemClock{},
+		Observer: NoopObserver{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if size <= 0 {
+		return o, ErrInvalidSize
+	}
+
+	if o.Weigher != nil && o.MaxWeight <= 0 {
+		return o, ErrInvalidMaxWeight
+	}
+
+	if o.CleanupInterval < 0 {
+		return o, ErrInvalidCleanupInterval
+	}
+
+	if o.EvictionWorkers < 0 || o.EvictionBuffer < 0 {
+		return o, ErrInvalidAsyncEviction
+	}
+
+	if o.RefreshLoader != nil && (o.RefreshAhead <= 0 || o.RefreshAhead > 1) {
+		return o, ErrInvalidRefreshAhead
+	}
+
+	if o.NegativeSize < 0 || (o.NegativeSize > 0 && (o.NotFoundTTL <= 0 || o.ErrorTTL < 0)) {
+		return o, ErrInvalidNegativeCaching
+	}
+
+	if o.Clock == nil {
+		o.Clock = SystemClock{}
+	}
+
+	if o.Observer == nil {
+		o.Observer = NoopObserver{}
+	}
+
+	return o, nil
+}
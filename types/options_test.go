@@ -0,0 +1,43 @@
+package types
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fortio.org/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	weigher := func(_ string, value string) int64 {
+		return int64(len(value))
+	}
+
+	o, err := NewOptions[string, string](10,
+		WithTTL[string, string](time.Minute),
+		WithWeigher[string, string](weigher, 100),
+		WithCleanupInterval[string, string](time.Second),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, o.TTL)
+	assert.Equal(t, int64(100), o.MaxWeight)
+	assert.Equal(t, time.Second, o.CleanupInterval)
+
+	// negative ttl means no expiration
+	o, err = NewOptions[string, string](10, WithTTL[string, string](-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), o.TTL)
+}
+
+func TestNewOptionsValidation(t *testing.T) {
+	_, err := NewOptions[string, string](0)
+	assert.True(t, errors.Is(err, ErrInvalidSize))
+
+	_, err = NewOptions[string, string](10, WithWeigher[string, string](func(string, string) int64 {
+		return 1
+	}, 0))
+	assert.True(t, errors.Is(err, ErrInvalidMaxWeight))
+
+	_, err = NewOptions[string, string](10, WithCleanupInterval[string, string](-time.Second))
+	assert.True(t, errors.Is(err, ErrInvalidCleanupInterval))
+}
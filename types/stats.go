@@ -0,0 +1,48 @@
+package types
+
+// Stats holds the statistics of a cache.
+type Stats struct {
+	// Hits is the number of Get calls that found the key.
+	Hits uint64
+	// Misses is the number of Get calls that did not find the key.
+	Misses uint64
+	// Sets is the number of entries added to the cache.
+	Sets uint64
+	// Updates is the number of Set calls that replaced the value of an existing entry.
+	Updates uint64
+	// Evictions is the number of entries removed from the cache, indexed by [EvictReason].
+	Evictions [NumEvictReasons]uint64
+	// GhostHits is the number of entries added straight into the main queue
+	// because their key was found in the ghost queue. It's only used by S3-FIFO.
+	GhostHits uint64
+	// NegativeHits is the number of loading gets answered by a cached failed load.
+	NegativeHits uint64
+	// NegativeSets is the number of failed loads cached by negative caching.
+	NegativeSets uint64
+	// Rejections is the number of new keys not set because the admission policy
+	// preferred the entry that would have been evicted for them.
+	Rejections uint64
+}
+
+// Expirations returns the number of entries removed because their TTL has expired.
+func (s Stats) Expirations() uint64 {
+	return s.Evictions[EvictReasonExpired]
+}
+
+// Evicted returns the number of entries removed from the cache for the reason,
+// or zero if the reason is unknown.
+func (s Stats) Evicted(reason EvictReason) uint64 {
+	if reason < 0 || reason >= NumEvictReasons {
+		return 0
+	}
+	return s.Evictions[reason]
+}
+
+// HitRatio returns the ratio of hits to the total number of Get calls.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
@@ -0,0 +1,23 @@
+package types
+
+import (
+	"testing"
+
+	"fortio.org/assert"
+)
+
+func TestStatsEvicted(t *testing.T) {
+	var s Stats
+	for reason := EvictReason(0); reason < NumEvictReasons; reason++ {
+		s.Evictions[reason] = uint64(reason) + 1
+	}
+
+	assert.Equal(t, uint64(1), s.Evicted(EvictReasonExpired))
+	assert.Equal(t, uint64(2), s.Evicted(EvictReasonEvicted))
+	assert.Equal(t, uint64(3), s.Evicted(EvictReasonRemoved))
+	assert.Equal(t, uint64(1), s.Expirations())
+
+	// unknown reasons have no evictions
+	assert.Equal(t, uint64(0), s.Evicted(-1))
+	assert.Equal(t, uint64(0), s.Evicted(NumEvictReasons))
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"context"
+	"errors"
+)
+
 // EvictReason is the reason for an entry to be evicted from the cache.
 // It is used in the [OnEvictCallback] function.
 type EvictReason int
@@ -11,10 +16,35 @@ const (
 	EvictReasonEvicted
 	// EvictReasonRemoved is used when an item is explicitly deleted.
 	EvictReasonRemoved
+
+	// NumEvictReasons is the number of the eviction reasons above,
+	// to iterate over them or size arrays indexed by [EvictReason].
+	NumEvictReasons
 )
 
+// String returns the name of the reason, such as "expired".
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonEvicted:
+		return "evicted"
+	case EvictReasonRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
 type OnEvictCallback[K comparable, V any] func(key K, value V, reason EvictReason)
 
+// PanicHandler is called with the value recovered from a panic of the eviction callback,
+// and the eviction the callback was called for.
+type PanicHandler[K comparable, V any] func(key K, value V, reason EvictReason, recovered any)
+
+// Weigher returns the weight of a cache entry, such as the size of the value in bytes.
+// The weight of an entry must not be negative, the cache panics otherwise.
+type Weigher[K comparable, V any] func(key K, value V) int64
+
 // Cache is the interface for a cache.
 type Cache[K comparable, V any] interface {
 	// Set sets the value for the given key on cache.
@@ -33,14 +63,97 @@ type Cache[K comparable, V any] interface {
 	Peek(key K) (value V, ok bool)
 
 	// SetOnEvicted sets the callback function that will be called when an entry is evicted from the cache.
+	// A panic of the callback is recovered, so that it doesn't break the cache,
+	// and reported to the handler set with [WithOnCallbackPanic], if any.
 	SetOnEvicted(callback OnEvictCallback[K, V])
 
 	// Len returns the number of entries in the cache.
 	Len() int
 
-	// Purge clears all cache entries
+	// Purge clears all cache entries, calling the eviction callback with EvictReasonRemoved for each of them.
 	Purge()
 
 	// Close closes the cache and releases any resources associated with it.
 	Close()
 }
+
+// Loader loads the value for the given key when it is missing in the cache.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (value V, err error)
+
+// ErrNotFound is returned by a loader, possibly wrapped, when the key doesn't exist.
+// With negative caching, the loading get returns it without calling the loader again until it expires.
+var ErrNotFound = errors.New("not found")
+
+// LoadingCache is the interface for a cache that loads missing values on demand.
+type LoadingCache[K comparable, V any] interface {
+	Cache[K, V]
+
+	// GetOrLoad gets the value for the given key from cache,
+	// or loads it with the loader and sets it on cache if it is missing.
+	// Concurrent calls missing the same key share a single loader call and its error.
+	// It returns the context error if ctx is done before the value is loaded.
+	GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (value V, err error)
+}
+
+// StaleLoadingCache is the interface for a loading cache that can serve expired values when their reload fails.
+type StaleLoadingCache[K comparable, V any] interface {
+	LoadingCache[K, V]
+
+	// GetOrLoadStale works like GetOrLoad, but if the loader fails to reload an expired entry
+	// still in its stale grace period, it returns the expired value with stale set and a nil error.
+	// If ctx is done before the entry is reloaded, it returns the expired value with stale set and the context error.
+	GetOrLoadStale(ctx context.Context, key K, loader Loader[K, V]) (value V, stale bool, err error)
+}
+
+// Entry is a key-value pair of a cache.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// BatchCache is the interface for a cache performing a batch of operations under a single lock.
+type BatchCache[K comparable, V any] interface {
+	Cache[K, V]
+
+	// SetMany sets the given entries on cache in order.
+	SetMany(entries []Entry[K, V])
+
+	// GetMany gets the values for the given keys from cache.
+	// The returned map only holds the keys found in the cache.
+	GetMany(keys []K) map[K]V
+
+	// RemoveMany removes the given keys from the cache in order.
+	// The returned slice reports whether each key was removed, aligned with keys.
+	RemoveMany(keys []K) []bool
+}
+
+// ComputeOp is the operation applied to an entry by [AtomicCache.Compute].
+type ComputeOp int
+
+const (
+	// ComputeKeep leaves the entry as it is.
+	ComputeKeep ComputeOp = iota
+	// ComputeUpdate sets the computed value for the key.
+	ComputeUpdate
+	// ComputeDelete removes the entry.
+	ComputeDelete
+)
+
+// AtomicCache is the interface for a cache performing read-modify-write operations atomically.
+type AtomicCache[K comparable, V any] interface {
+	Cache[K, V]
+
+	// GetOrSet returns the existing value for the key if present.
+	// Otherwise, it sets and returns the given value. The loaded result is true if the value was present.
+	GetOrSet(key K, value V) (actual V, loaded bool)
+
+	// Compute calls fn with the current value for the key, or ok=false if it's absent,
+	// and applies the returned operation. It returns the value for the key afterward.
+	// An updated entry keeps its own TTL, and a new one gets the default TTL.
+	// The fn is called under the lock of the cache, so it must not call the cache.
+	Compute(key K, fn func(old V, ok bool) (V, ComputeOp)) (value V, ok bool)
+
+	// CompareAndSwap sets the new value for the key if the current value is equal to old.
+	// The entry keeps its own TTL. It panics if the type of the values is not comparable.
+	CompareAndSwap(key K, old, new V) (swapped bool)
+}